@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/jobs"
+	"banana-weather/pkg/presets"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PresetRequest is the body of POST and PATCH /api/admin/presets. On PATCH,
+// omitted fields are left unchanged.
+type PresetRequest struct {
+	ID            string  `json:"id"` // POST only
+	Name          *string `json:"name"`
+	Category      *string `json:"category"`
+	CityQuery     *string `json:"city_query"`
+	PromptContext *string `json:"prompt_context"`
+}
+
+func (p *PresetRequest) apply(loc *database.Location) {
+	if p.Name != nil {
+		loc.Name = *p.Name
+	}
+	if p.Category != nil {
+		loc.Category = *p.Category
+	}
+	if p.CityQuery != nil {
+		loc.CityQuery = *p.CityQuery
+	}
+	if p.PromptContext != nil {
+		loc.PromptContext = *p.PromptContext
+	}
+}
+
+// validPresetID reports whether id is a non-empty run of a-z, 0-9 and _.
+// Preset IDs are chosen by hand and stay ASCII, unlike user location IDs.
+func validPresetID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Handler) presetGenerator() *presets.Generator {
+	return &presets.Generator{
+		Images:  h.Images,
+		Videos:  h.Videos,
+		Storage: h.Storage,
+		DB:      h.DB,
+	}
+}
+
+// getPreset loads a preset, writing a 404 (or 500) and returning nil if it
+// can't.
+func (h *Handler) getPreset(w http.ResponseWriter, r *http.Request) *database.Location {
+	id := chi.URLParam(r, "id")
+	loc, err := h.DB.GetLocation(r.Context(), database.PresetNamespace, id)
+	if err == nil && loc.IsPreset {
+		return loc
+	}
+	if err == nil || errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return nil
+	}
+	log.Printf("Failed to get preset %s: %v", id, err)
+	http.Error(w, "Failed to fetch preset", http.StatusInternalServerError)
+	return nil
+}
+
+// HandleAdminListPresets serves GET /api/admin/presets, including presets
+// whose media hasn't been generated yet.
+func (h *Handler) HandleAdminListPresets(w http.ResponseWriter, r *http.Request) {
+	presets, err := h.DB.GetPresets(r.Context())
+	if err != nil {
+		log.Printf("Failed to get presets from DB: %v", err)
+		http.Error(w, "Failed to fetch presets", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, presets)
+}
+
+// HandleAdminGetPreset serves GET /api/admin/presets/{id}.
+func (h *Handler) HandleAdminGetPreset(w http.ResponseWriter, r *http.Request) {
+	if loc := h.getPreset(w, r); loc != nil {
+		writeJSON(w, http.StatusOK, loc)
+	}
+}
+
+// HandleAdminCreatePreset serves POST /api/admin/presets. It only stores the
+// metadata; media is generated by the regenerate endpoint, and until then the
+// preset is left out of /api/presets.
+func (h *Handler) HandleAdminCreatePreset(w http.ResponseWriter, r *http.Request) {
+	var req PresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validPresetID(req.ID) {
+		http.Error(w, "id is required and may only contain a-z, 0-9 and _", http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil || *req.Name == "" || req.CityQuery == nil || *req.CityQuery == "" {
+		http.Error(w, "name and city_query are required", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.DB.GetLocation(r.Context(), database.PresetNamespace, req.ID)
+	if err == nil && existing != nil {
+		http.Error(w, "A preset with this id already exists", http.StatusConflict)
+		return
+	}
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		log.Printf("Failed to check preset %s: %v", req.ID, err)
+		http.Error(w, "Failed to create preset", http.StatusInternalServerError)
+		return
+	}
+
+	loc := database.Location{ID: req.ID, Category: "General", IsPreset: true}
+	req.apply(&loc)
+	if err := h.DB.UpsertLocation(r.Context(), loc); err != nil {
+		log.Printf("Failed to create preset %s: %v", req.ID, err)
+		http.Error(w, "Failed to create preset", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Admin: created preset %s", loc.ID)
+	writeJSON(w, http.StatusCreated, loc)
+}
+
+// HandleAdminPatchPreset serves PATCH /api/admin/presets/{id}, updating
+// metadata only.
+func (h *Handler) HandleAdminPatchPreset(w http.ResponseWriter, r *http.Request) {
+	loc := h.getPreset(w, r)
+	if loc == nil {
+		return
+	}
+
+	var req PresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID != "" && req.ID != loc.ID {
+		http.Error(w, "id cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	// Only the requested fields are written, so a regeneration finishing
+	// meanwhile keeps its media.
+	var updated database.Location
+	invalid := false
+	err := h.DB.UpdateLocation(r.Context(), database.PresetNamespace, loc.ID, func(current *database.Location) bool {
+		req.apply(current)
+		invalid = current.Name == "" || current.CityQuery == ""
+		updated = *current
+		return !invalid
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update preset %s: %v", loc.ID, err)
+		http.Error(w, "Failed to update preset", http.StatusInternalServerError)
+		return
+	}
+	if invalid {
+		http.Error(w, "name and city_query cannot be empty", http.StatusBadRequest)
+		return
+	}
+	log.Printf("Admin: updated preset %s", loc.ID)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// HandleAdminDeletePreset serves DELETE /api/admin/presets/{id}. Its media
+// is left in storage.
+func (h *Handler) HandleAdminDeletePreset(w http.ResponseWriter, r *http.Request) {
+	loc := h.getPreset(w, r)
+	if loc == nil {
+		return
+	}
+	if err := h.DB.DeleteLocation(r.Context(), database.PresetNamespace, loc.ID); err != nil {
+		log.Printf("Failed to delete preset %s: %v", loc.ID, err)
+		http.Error(w, "Failed to delete preset", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Admin: deleted preset %s", loc.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminRegeneratePreset serves POST /api/admin/presets/{id}/regenerate.
+// It regenerates the image and video as a job and streams its progress as
+// SSE, like /api/weather: "status" events, then "result" (the updated preset
+// as JSON) and "video", or "error".
+func (h *Handler) HandleAdminRegeneratePreset(w http.ResponseWriter, r *http.Request) {
+	loc := h.getPreset(w, r)
+	if loc == nil {
+		return
+	}
+
+	h.streamJob(w, r, func() (string, *ErrorEvent) {
+		log.Printf("Admin: regenerating preset %s", loc.ID)
+		return h.Jobs.Start("preset:"+loc.ID, func(ctx context.Context, job *jobs.Handle) {
+			h.runPresetJob(ctx, job, *loc)
+		}), nil
+	})
+}
+
+// runPresetJob generates new media for preset. Only the media is saved, so
+// metadata edited while it runs is kept.
+func (h *Handler) runPresetJob(ctx context.Context, job *jobs.Handle, preset database.Location) {
+	job.Update(func(j *database.Job) { j.LocationID = preset.ID })
+
+	// Regenerating a preset twice at once would only waste quota. Presets
+	// are leased apart from user locations, which may share their IDs.
+	leaderID, err := job.Lead(ctx, "preset:"+preset.ID)
+	if err != nil {
+		log.Printf("Coalescing unavailable for %s: %v", preset.ID, err)
+	} else if leaderID != job.ID() {
+		h.followLeader(ctx, job, caller{}, leaderID, false)
+		return
+	}
+
+	job.Update(func(j *database.Job) { j.Stage = database.JobGeneratingImage })
+	loc, err := h.presetGenerator().GenerateLocation(ctx, preset, func(msg string) {
+		job.Emit("status", msg)
+	})
+	if err != nil {
+		log.Printf("Failed to regenerate preset %s: %v", preset.ID, err)
+		job.Fail("Failed to regenerate preset: " + err.Error())
+		return
+	}
+
+	job.Update(func(j *database.Job) {
+		j.ImageURL = loc.ImageURL
+		j.VideoURL = loc.VideoURL
+	})
+	jsonData, _ := json.Marshal(loc)
+	job.Emit("result", string(jsonData))
+	job.Emit("video", loc.VideoURL)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
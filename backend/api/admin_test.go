@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"banana-weather/pkg/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newAdminServer(t *testing.T, h *Handler) *httptest.Server {
+	t.Helper()
+	r := chi.NewRouter()
+	r.Get("/presets", h.HandleGetPresets)
+	r.Patch("/admin/presets/{id}", h.HandleAdminPatchPreset)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func patch(t *testing.T, url, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("PATCH", url, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAdminPatchPreset(t *testing.T) {
+	h := newDevHandler(t)
+	srv := newAdminServer(t, h)
+	ctx := context.Background()
+	h.DB.UpsertLocation(ctx, database.Location{
+		ID: "paris", Name: "Paris", Category: "Europe", CityQuery: "Paris, France",
+		ImageURL: "http://media.test/paris.png", VideoURL: "http://media.test/paris.mp4", IsPreset: true,
+	})
+
+	resp := patch(t, srv.URL+"/admin/presets/paris", `{"name": "Paris, France", "category": "Capitals"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	var got database.Location
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := h.DB.GetLocation(ctx, database.PresetNamespace, "paris")
+	for _, loc := range []*database.Location{&got, stored} {
+		if loc.Name != "Paris, France" || loc.Category != "Capitals" || loc.CityQuery != "Paris, France" || loc.VideoURL != "http://media.test/paris.mp4" {
+			t.Errorf("after PATCH: %+v, want the new name and category and the old media", loc)
+		}
+	}
+
+	for _, tt := range []struct {
+		id, body string
+		want     int
+	}{
+		{"paris", `{"name": ""}`, http.StatusBadRequest},
+		{"paris", `{"id": "rome"}`, http.StatusBadRequest},
+		{"paris", `{`, http.StatusBadRequest},
+		{"rome", `{"name": "Rome"}`, http.StatusNotFound},
+	} {
+		if resp := patch(t, srv.URL+"/admin/presets/"+tt.id, tt.body); resp.StatusCode != tt.want {
+			t.Errorf("PATCH %s %s: status %d, want %d", tt.id, tt.body, resp.StatusCode, tt.want)
+		}
+	}
+	if stored, _ := h.DB.GetLocation(ctx, database.PresetNamespace, "paris"); stored.Name != "Paris, France" {
+		t.Errorf("rejected PATCH changed the name to %q", stored.Name)
+	}
+}
+
+func TestPresetsHidesUngenerated(t *testing.T) {
+	h := newDevHandler(t)
+	srv := newAdminServer(t, h)
+	ctx := context.Background()
+	h.DB.UpsertLocation(ctx, database.Location{ID: "paris", Name: "Paris", ImageURL: "http://media.test/paris.png", IsPreset: true})
+	h.DB.UpsertLocation(ctx, database.Location{ID: "rome", Name: "Rome", IsPreset: true})
+
+	resp, err := http.Get(srv.URL + "/presets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got []database.Location
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "paris" {
+		t.Errorf("presets %+v, want only paris", got)
+	}
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"banana-weather/pkg/geocache"
+	"banana-weather/pkg/ratelimit"
+)
+
+const (
+	// maxSuggestions caps the suggestions of one response.
+	maxSuggestions = 10
+	// minAutocompleteLen is how many characters must be typed before Maps
+	// is asked; shorter queries only match presets and recent locations.
+	minAutocompleteLen = 2
+	// recentPool is how many recently generated locations are matched.
+	recentPool = 200
+	// autocompleteTTL is how long Maps suggestions for a query are reused.
+	// Typing, deleting and retyping a character asks Maps once.
+	autocompleteTTL = 10 * time.Minute
+	// localSuggestionsTTL is how long the preset and recent location lists
+	// are reused, so every keystroke doesn't read the database.
+	localSuggestionsTTL = time.Minute
+	// localSuggestionsTimeout bounds reading those lists.
+	localSuggestionsTimeout = 5 * time.Second
+	// autocompleteCacheSize caps the queries kept in memory.
+	autocompleteCacheSize = 10000
+)
+
+// Suggestion is a completion of what the user typed. Presets are loaded
+// from /api/presets by PresetID; anything else is requested from
+// /api/weather by PlaceID if it has one, or else by Text as the city.
+type Suggestion struct {
+	Text     string `json:"text"`
+	PlaceID  string `json:"place_id,omitempty"`
+	PresetID string `json:"preset_id,omitempty"`
+	Source   string `json:"source"` // "preset", "recent" or "maps"
+}
+
+// Suggestions is the response of /api/autocomplete.
+type Suggestions struct {
+	Query       string       `json:"query"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// autocompleteCache remembers Maps suggestions per normalized query, and
+// the presets and recent locations they are merged with. The zero value is
+// ready to use.
+type autocompleteCache struct {
+	mu           sync.Mutex
+	queries      map[string]cachedSuggestions
+	local        []Suggestion // Presets first, then recent locations
+	localExpires time.Time
+	refreshing   bool // Whether a request is reading local
+}
+
+type cachedSuggestions struct {
+	suggestions []Suggestion
+	expires     time.Time
+}
+
+// HandleAutocomplete serves GET /api/autocomplete?q=...: matching preset
+// names, recently generated locations and Maps cities, in that order.
+// Responses may be cached by the browser for a minute.
+// Queries that ask Maps use a unit of the client's autocomplete budget; over
+// it, only presets and recent locations are suggested.
+func (h *Handler) HandleAutocomplete(w http.ResponseWriter, r *http.Request) {
+	q, e := validateCity(r.URL.Query().Get("q"))
+	if e != nil {
+		e.Param = "q"
+		writeError(w, http.StatusBadRequest, *e)
+		return
+	}
+	query := geocache.NormalizeQuery(q)
+	if query == "" {
+		writeError(w, http.StatusBadRequest, ErrorEvent{Code: "missing_query", Message: "Missing query parameter q", Param: "q"})
+		return
+	}
+
+	var suggestions []Suggestion
+	for _, s := range h.localSuggestions() {
+		if strings.HasPrefix(geocache.NormalizeQuery(s.Text), query) {
+			suggestions = append(suggestions, s)
+		}
+	}
+	if h.Autocomplete != nil && utf8.RuneCountInString(query) >= minAutocompleteLen {
+		suggestions = append(suggestions, h.mapsSuggestions(r.Context(), query)...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	json.NewEncoder(w).Encode(Suggestions{Query: q, Suggestions: dedupeSuggestions(suggestions)})
+}
+
+// localSuggestions returns all presets and recently generated locations. The
+// lists are read without holding the lock, and with a context of their own
+// so that a cancelled request doesn't cut them short; concurrent requests
+// meanwhile get the previous lists. They are only cached if both reads
+// succeed; otherwise the previous lists (or what could be read) are used.
+func (h *Handler) localSuggestions() []Suggestion {
+	c := &h.autocomplete
+	now := time.Now()
+	c.mu.Lock()
+	local, expires := c.local, c.localExpires
+	refresh := !now.Before(expires) && !c.refreshing
+	if refresh {
+		c.refreshing = true
+	}
+	c.mu.Unlock()
+	if !refresh {
+		return local
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), localSuggestionsTimeout)
+	defer cancel()
+	fresh, err := h.readLocalSuggestions(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshing = false
+	if err != nil {
+		log.Printf("Failed to read autocomplete suggestions: %v", err)
+		if local != nil {
+			return local
+		}
+		return fresh
+	}
+	c.local, c.localExpires = fresh, now.Add(localSuggestionsTTL)
+	return fresh
+}
+
+// readLocalSuggestions reads the presets, then the recent locations. On error
+// it returns what it has read so far.
+func (h *Handler) readLocalSuggestions(ctx context.Context) ([]Suggestion, error) {
+	var local []Suggestion
+	presets, err := h.DB.GetPresets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("presets: %w", err)
+	}
+	for _, p := range published(presets) {
+		local = append(local, Suggestion{Text: p.Name, PresetID: p.ID, Source: "preset"})
+	}
+	recent, err := h.DB.ListRecentLocations(ctx, recentPool)
+	if err != nil {
+		return local, fmt.Errorf("recent locations: %w", err)
+	}
+	for _, loc := range recent {
+		local = append(local, Suggestion{Text: loc.Name, PlaceID: loc.PlaceID, Source: "recent"})
+	}
+	return local, nil
+}
+
+// mapsSuggestions returns the Maps suggestions for a normalized query. Maps
+// errors and an exhausted autocomplete budget suggest nothing.
+func (h *Handler) mapsSuggestions(ctx context.Context, query string) []Suggestion {
+	c := &h.autocomplete
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.queries[query]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.suggestions
+	}
+	if err := ratelimit.FromContext(ctx).Take(ctx, ratelimit.Autocomplete); err != nil {
+		return nil
+	}
+
+	results, err := h.Autocomplete.Autocomplete(ctx, query)
+	if err != nil {
+		log.Printf("Autocomplete failed for '%s': %v", query, err)
+		return nil
+	}
+	suggestions := make([]Suggestion, len(results))
+	for i, s := range results {
+		suggestions[i] = Suggestion{Text: s.Text, PlaceID: s.PlaceID, Source: "maps"}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queries == nil || len(c.queries) >= autocompleteCacheSize {
+		// Dropping everything is rare and only costs a few Maps requests.
+		c.queries = make(map[string]cachedSuggestions)
+	}
+	c.queries[query] = cachedSuggestions{suggestions: suggestions, expires: now.Add(autocompleteTTL)}
+	return suggestions
+}
+
+// dedupeSuggestions keeps the first suggestion of each place and name, up to
+// maxSuggestions.
+func dedupeSuggestions(suggestions []Suggestion) []Suggestion {
+	deduped := []Suggestion{}
+	seen := make(map[string]bool)
+	for _, s := range suggestions {
+		name := "name:" + geocache.NormalizeQuery(s.Text)
+		place := "place:" + s.PlaceID
+		if seen[name] || (s.PlaceID != "" && seen[place]) {
+			continue
+		}
+		seen[name], seen[place] = true, true
+		deduped = append(deduped, s)
+		if len(deduped) == maxSuggestions {
+			break
+		}
+	}
+	return deduped
+}
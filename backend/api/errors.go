@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"banana-weather/pkg/jobs"
+	"banana-weather/pkg/ratelimit"
+)
+
+// ErrorEvent is the data of an "error" event that clients may want to act on,
+// rather than just display. Other error events carry a plain message.
+type ErrorEvent struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after,omitempty"` // Seconds
+	Param      string `json:"param,omitempty"`       // The rejected parameter
+}
+
+// rateLimitError describes a *ratelimit.LimitError for an "error" event.
+func rateLimitError(err error) ErrorEvent {
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) {
+		return ErrorEvent{Code: "internal", Message: err.Error()}
+	}
+
+	var what string
+	switch limitErr.Class {
+	case ratelimit.Image:
+		what = "new forecasts"
+	case ratelimit.Video:
+		what = "animations"
+	case ratelimit.Geocode:
+		what = "place searches"
+	default:
+		what = "forecasts"
+	}
+	minutes := int(math.Ceil(limitErr.RetryAfter.Minutes()))
+	return ErrorEvent{
+		Code:       "rate_limited",
+		Message:    fmt.Sprintf("You've reached the limit for %s. Please try again in %d min.", what, minutes),
+		RetryAfter: int(math.Ceil(limitErr.RetryAfter.Seconds())),
+	}
+}
+
+func (e ErrorEvent) String() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// failRateLimited fails the job with a rate limit error event.
+func failRateLimited(job *jobs.Handle, err error) {
+	e := rateLimitError(err)
+	job.FailWith(e.Message, e.String())
+}
+
+// writeRateLimited responds 429 with a rate limit error.
+func writeRateLimited(w http.ResponseWriter, err error) {
+	e := rateLimitError(err)
+	w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	writeError(w, http.StatusTooManyRequests, e)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"banana-weather/pkg/maps"
+	"banana-weather/pkg/ratelimit"
+)
+
+// Choice is one of the places an ambiguous city name may mean.
+type Choice struct {
+	Name    string  `json:"name"` // Formatted address, e.g. "Springfield, IL, USA"
+	PlaceID string  `json:"place_id"`
+	Country string  `json:"country,omitempty"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+}
+
+// Choices is the data of a "choices" event, sent instead of a result when a
+// city name matches several places, and the response of /api/geocode. The
+// client asks for one of them with /api/weather?place_id=...
+type Choices struct {
+	Query   string   `json:"query"`
+	Choices []Choice `json:"choices"`
+}
+
+func newChoices(query string, places []maps.Place) Choices {
+	choices := Choices{Query: query, Choices: []Choice{}}
+	for _, p := range places {
+		choices.Choices = append(choices.Choices, Choice{
+			Name:    p.Name,
+			PlaceID: p.ID,
+			Country: p.Country,
+			Lat:     p.Lat,
+			Lng:     p.Lng,
+		})
+	}
+	return choices
+}
+
+// HandleGeocode serves GET /api/geocode?q=...: the places a search box query
+// may mean, best match first. Nothing found is an empty list. Each request
+// uses a unit of the client's geocode budget.
+func (h *Handler) HandleGeocode(w http.ResponseWriter, r *http.Request) {
+	query, e := validateCity(r.URL.Query().Get("q"))
+	if e != nil {
+		e.Param = "q"
+		writeError(w, http.StatusBadRequest, *e)
+		return
+	}
+	if query == "" {
+		writeError(w, http.StatusBadRequest, ErrorEvent{Code: "missing_query", Message: "Missing query parameter q", Param: "q"})
+		return
+	}
+	if err := ratelimit.FromContext(r.Context()).Take(r.Context(), ratelimit.Geocode); err != nil {
+		writeRateLimited(w, err)
+		return
+	}
+
+	places, err := h.Maps.FindCities(r.Context(), query)
+	if err != nil && !errors.Is(err, maps.ErrNotFound) {
+		log.Printf("Failed to geocode '%s': %v", query, err)
+		http.Error(w, "Failed to geocode", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newChoices(query, places))
+}
@@ -1,44 +1,84 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"banana-weather/pkg/auth"
 	"banana-weather/pkg/database"
+	"banana-weather/pkg/gazetteer"
 	"banana-weather/pkg/genai"
+	"banana-weather/pkg/jobs"
 	"banana-weather/pkg/maps"
+	"banana-weather/pkg/ratelimit"
 	"banana-weather/pkg/storage"
+	"banana-weather/pkg/weather"
 )
 
+// Handler serves the weather API. Every dependency is an interface so the
+// handler can be wired to Google Cloud services in production or to local
+// implementations in tests.
 type Handler struct {
-	Maps    *maps.Service
-	GenAI   *genai.Service
-	Storage *storage.Service
-	DB      *database.Client
+	Maps    maps.Geocoder
+	Images  genai.ImageGenerator
+	Videos  genai.VideoGenerator
+	Storage storage.BlobStore
+	DB      database.LocationStore
+	Weather weather.Provider // Optional: without it the image model looks up the weather
+	Jobs    *jobs.Manager
+
+	// Autocomplete suggests cities to /api/autocomplete. Optional: without
+	// it only presets and recent locations are suggested.
+	Autocomplete maps.Autocompleter
+
+	// Timezones estimates the timezone of places whose forecast doesn't
+	// give one. Optional: without it the nautical zone is used.
+	Timezones *gazetteer.Service
+
+	// VideoPool runs Veo generations on bounded, server-owned workers so they
+	// complete (and are cached) even if every client has gone away. Optional:
+	// without it videos are generated on the job's own goroutine.
+	VideoPool *jobs.Pool
+
+	// Limiter enforces per-client budgets on /api/weather (see main.go).
+	// Optional: without it requests are not limited.
+	Limiter *ratelimit.Limiter
+
+	// Auth validates API keys on all API routes, and Keys records their usage.
+	Auth *auth.Authenticator
+	Keys database.APIKeyStore
+
+	// Cache decides when cached locations are regenerated. The zero value
+	// uses the default TTLs.
+	Cache database.CachePolicy
+
+	autocomplete autocompleteCache
 }
 
 type WeatherResponse struct {
-	City        string `json:"city"`
-	ImageBase64 string `json:"image_base64,omitempty"`
-	ImageURL    string `json:"image_url,omitempty"`
+	City        string            `json:"city"`
+	ImageBase64 string            `json:"image_base64,omitempty"`
+	ImageURL    string            `json:"image_url,omitempty"`
+	Weather     *weather.Forecast `json:"weather,omitempty"`
+	Stale       bool              `json:"stale,omitempty"` // A fresh result follows on the same stream
 }
 
-func sanitizeID(s string) string {
-	var result []rune
-	for _, r := range strings.ToLower(s) {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
-			result = append(result, r)
-		} else {
-			result = append(result, '_')
-		}
-	}
-	return string(result)
+// StaleVideo is the data of a "video" event for a stale cached video. Fresh
+// videos are sent as a plain URL.
+type StaleVideo struct {
+	URL   string `json:"url"`
+	Stale bool   `json:"stale"`
 }
 
+// HandleGetPresets serves the gallery: the presets that have an image. New
+// presets appear once their media has been generated.
 func (h *Handler) HandleGetPresets(w http.ResponseWriter, r *http.Request) {
 	// Fetch from Firestore
 	presets, err := h.DB.GetPresets(r.Context())
@@ -49,10 +89,48 @@ func (h *Handler) HandleGetPresets(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(presets)
+	json.NewEncoder(w).Encode(published(presets))
+}
+
+// published returns the presets that have an image.
+func published(presets []database.Location) []database.Location {
+	shown := []database.Location{}
+	for _, p := range presets {
+		if p.ImageURL != "" {
+			shown = append(shown, p)
+		}
+	}
+	return shown
 }
 
 func (h *Handler) HandleGetWeather(w http.ResponseWriter, r *http.Request) {
+	h.streamJob(w, r, func() (string, *ErrorEvent) {
+		// Reject malformed requests before they reach a paid API.
+		query, e := parseWeatherQuery(r.URL.Query())
+		if e != nil {
+			log.Printf("Rejected weather request: %s (%s)", e.Code, e.Param)
+			return "", e
+		}
+
+		log.Printf("Received weather request. City: %s, Lat: %f, Lng: %f, Place: %s", query.City, query.Lat, query.Lng, query.PlaceID)
+
+		c := caller{
+			quota: ratelimit.FromContext(r.Context()),
+			key:   auth.FromContext(r.Context()),
+		}
+		return h.Jobs.Start(query.String(), func(ctx context.Context, job *jobs.Handle) {
+			h.runWeatherJob(ctx, job, c, query)
+		}), nil
+	})
+}
+
+// streamJob streams a job's events as SSE. The stream is a view onto a job:
+// reconnecting clients resume it either via Last-Event-ID ("<job>:<seq>",
+// sent automatically by EventSource) or by passing ?job=<id>. Otherwise start
+// is called to start a new job, or to reject the request with an error
+// event. The event is sent with status 200 like any other, since EventSource
+// ignores the body of other responses.
+func (h *Handler) streamJob(w http.ResponseWriter, r *http.Request, start func() (string, *ErrorEvent)) {
 	// Check for SSE support
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -65,139 +143,484 @@ func (h *Handler) HandleGetWeather(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Helper to send SSE events
-	sendEvent := func(event string, data string) {
-		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	jobID, after := parseEventID(r.Header.Get("Last-Event-ID"))
+	if q := r.URL.Query().Get("job"); q != "" && q != jobID {
+		jobID, after = q, 0
+	}
+
+	if jobID == "" {
+		var e *ErrorEvent
+		jobID, e = start()
+		if e != nil {
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", e.String())
+			flusher.Flush()
+			return
+		}
+	} else {
+		log.Printf("Resuming job %s after event %d", jobID, after)
+	}
+
+	err := h.Jobs.Follow(r.Context(), jobID, after, func(e database.JobEvent) error {
+		_, err := fmt.Fprintf(w, "id: %s:%d\nevent: %s\ndata: %s\n\n", jobID, e.ID, e.Event, e.Data)
+		flusher.Flush()
+		return err
+	})
+	if errors.Is(err, jobs.ErrEnded) {
+		// Nothing left to send. 204 stops EventSource from reconnecting.
+		w.WriteHeader(http.StatusNoContent)
+	} else if errors.Is(err, database.ErrNotFound) {
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", "Job not found. Please try again.")
 		flusher.Flush()
+	} else if err != nil && r.Context().Err() == nil {
+		log.Printf("Error streaming job %s: %v", jobID, err)
+	}
+}
+
+// parseEventID splits an SSE event ID of the form "<job>:<seq>".
+func parseEventID(id string) (string, int) {
+	jobID, seqStr, ok := strings.Cut(id, ":")
+	if !ok {
+		return "", 0
+	}
+	seq, err := strconv.Atoi(seqStr)
+	if err != nil || seq < 0 {
+		return "", 0
 	}
+	return jobID, seq
+}
 
-	city := r.URL.Query().Get("city")
-	latStr := r.URL.Query().Get("lat")
-	lngStr := r.URL.Query().Get("lng")
+// findCached returns the user location place is cached as: the one with its
+// place ID or else, within h.Cache.MatchRadiusKm, the nearest one. It returns
+// nil if there is none; the place then gets a location of its own.
+func (h *Handler) findCached(ctx context.Context, place maps.Place) *database.Location {
+	if place.ID != "" {
+		loc, err := h.DB.FindLocationByPlaceID(ctx, place.ID)
+		if err == nil {
+			return loc
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			log.Printf("Failed to look up place %s: %v", place.ID, err)
+			return nil
+		}
+	}
 
-	var formattedCity string
-	var err error
+	if h.Cache.MatchRadiusKm == 0 || (place.Lat == 0 && place.Lng == 0) {
+		return nil
+	}
+	nearby, err := h.DB.ListLocationsInCells(ctx, database.CellsAround(place.Lat, place.Lng, h.Cache.MatchRadiusKm))
+	if err != nil {
+		log.Printf("Failed to look up locations near %s: %v", place.Name, err)
+		return nil
+	}
+	var nearest *database.Location
+	nearestDist := h.Cache.MatchRadiusKm
+	for i := range nearby {
+		if d := gazetteer.Distance(place.Lat, place.Lng, nearby[i].Lat, nearby[i].Lng); d <= nearestDist {
+			nearest, nearestDist = &nearby[i], d
+		}
+	}
+	if nearest != nil {
+		log.Printf("Serving %s for %s (%.1f km away)", nearest.Name, place.Name, nearestDist)
+	}
+	return nearest
+}
+
+// checkCache returns the cached entry for locID (nil if there is none) and
+// whether it is fresh (see database.CachePolicy), judged against forecast if
+// the lookup succeeded. lat and lng locate entries cached before their
+// timezone was recorded.
+func (h *Handler) checkCache(ctx context.Context, locID string, lat, lng float64, forecast *weather.Forecast) (*database.Location, bool) {
+	cachedLoc, err := h.DB.GetLocation(ctx, database.UserNamespace, locID)
+	if err != nil || cachedLoc == nil {
+		return nil, false
+	}
+	if cachedLoc.Timezone == "" {
+		f := forecast
+		if f == nil {
+			f = cachedLoc.Forecast
+		}
+		cachedLoc.Timezone = h.timezoneAt(lat, lng, f)
+	}
+	return cachedLoc, !h.Cache.StaleAgainst(cachedLoc, forecast, time.Now())
+}
+
+// timezoneAt returns the IANA timezone of a place: the forecast's, which the
+// weather provider looked up for the exact coordinates, or else an estimate.
+func (h *Handler) timezoneAt(lat, lng float64, forecast *weather.Forecast) string {
+	if forecast != nil && forecast.Timezone != "" {
+		if _, err := time.LoadLocation(forecast.Timezone); err == nil {
+			return forecast.Timezone
+		}
+	}
+	return h.Timezones.TimezoneAt(lat, lng)
+}
+
+// serveCached sends a cached entry's result and video. Stale entries are
+// flagged, telling the client that a fresh result follows. It returns false
+// if the client is over its cache budget, in which case the job has failed.
+func (h *Handler) serveCached(ctx context.Context, job *jobs.Handle, c caller, cachedLoc *database.Location, formattedCity string, stale bool) bool {
+	if err := c.quota.Take(ctx, ratelimit.Cache); err != nil {
+		failRateLimited(job, err)
+		return false
+	}
+
+	if stale {
+		log.Printf("Serving stale %s while regenerating", formattedCity)
+		job.Emit("status", "Showing the last forecast while a new one is made...")
+	} else {
+		log.Printf("Cache Hit for %s", formattedCity)
+		job.Emit("status", "Loading cached forecast...")
+	}
+
+	job.Update(func(j *database.Job) {
+		j.ImageURL = cachedLoc.ImageURL
+		j.VideoURL = cachedLoc.VideoURL
+	})
+
+	resp := WeatherResponse{
+		City:     formattedCity,
+		ImageURL: cachedLoc.ImageURL,
+		Weather:  cachedLoc.Forecast,
+		Stale:    stale,
+	}
+	jsonData, _ := json.Marshal(resp)
+	job.Emit("result", string(jsonData))
+
+	if cachedLoc.VideoURL != "" {
+		if stale {
+			videoData, _ := json.Marshal(StaleVideo{URL: cachedLoc.VideoURL, Stale: true})
+			job.Emit("video", string(videoData))
+		} else {
+			job.Emit("video", cachedLoc.VideoURL)
+		}
+	}
+	h.recordUsage(ctx, c, database.UsageCacheHits)
+	return true
+}
+
+// isStaleEvent reports whether e is a stale result or video sent by
+// serveCached.
+func isStaleEvent(e database.JobEvent) bool {
+	return (e.Event == "result" || e.Event == "video") && strings.Contains(e.Data, `"stale":true`)
+}
+
+// followLeader mirrors the progress and results of the job that is already
+// generating this location, on this or another instance. If the job has
+// already served the stale entry (and paid for it), the leader's copy of it
+// is skipped.
+func (h *Handler) followLeader(ctx context.Context, job *jobs.Handle, c caller, leaderID string, servedStale bool) {
+	// Followers cost us no more than a cache hit.
+	if !servedStale {
+		if err := c.quota.Take(ctx, ratelimit.Cache); err != nil {
+			failRateLimited(job, err)
+			return
+		}
+	}
+
+	job.Emit("status", "Joining a forecast already in progress...")
+
+	// Replay everything the leader has sent so far except status messages
+	// that have already been superseded.
+	var lastStatus int
+	if leader, err := h.Jobs.Get(ctx, leaderID); err == nil {
+		for _, e := range leader.Events {
+			if e.Event == "status" {
+				lastStatus = e.ID
+			}
+		}
+	}
+
+	err := h.Jobs.Follow(ctx, leaderID, 0, func(e database.JobEvent) error {
+		switch {
+		case e.Event == "job" || e.Event == "done":
+		case e.Event == "status" && e.ID < lastStatus:
+		case servedStale && isStaleEvent(e):
+		default:
+			job.Emit(e.Event, e.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to follow job %s: %v", leaderID, err)
+		job.Fail("Failed to follow forecast in progress. Please try again.")
+		return
+	}
+	if !servedStale {
+		h.recordUsage(ctx, c, database.UsageCacheHits)
+	}
 
-	log.Printf("Received weather request. City: %s, Lat: %s, Lng: %s", city, latStr, lngStr)
+	leader, err := h.Jobs.Get(ctx, leaderID)
+	if err != nil {
+		return
+	}
+	job.Update(func(j *database.Job) {
+		j.ImageURL = leader.ImageURL
+		j.VideoURL = leader.VideoURL
+		if leader.Stage == database.JobFailed {
+			j.Stage = database.JobFailed
+			j.Error = leader.Error
+		}
+	})
+}
 
+// runWeatherJob resolves the location, then serves it from cache or generates
+// a new image and video. It runs in the background; progress is reported as
+// job events. A city name that matches several places ends the job with a
+// "choices" event instead; the client then asks again with a place ID.
+func (h *Handler) runWeatherJob(ctx context.Context, job *jobs.Handle, c caller, query weatherQuery) {
+	sendEvent := job.Emit
+
+	var place maps.Place
+	lat, lng := query.Lat, query.Lng
+	var err error
+
+	job.Update(func(j *database.Job) { j.Stage = database.JobResolving })
 	sendEvent("status", "Identifying location...")
 
-	if latStr != "" && lngStr != "" {
+	switch {
+	case query.PlaceID != "":
+		// Handle a place chosen from a "choices" event
+		place, err = h.Maps.GetPlace(ctx, query.PlaceID)
+		if err != nil {
+			log.Printf("Error resolving place '%s': %v", query.PlaceID, err)
+			job.Fail("Failed to find place: " + err.Error())
+			return
+		}
+		lat, lng = place.Lat, place.Lng
+	case query.HasCoords:
 		// Handle Coordinates
-		var lat, lng float64
-		fmt.Sscanf(latStr, "%f", &lat)
-		fmt.Sscanf(lngStr, "%f", &lng)
-		
-		formattedCity, err = h.Maps.GetReverseGeocoding(r.Context(), lat, lng)
+		place, err = h.Maps.GetReverseGeocoding(ctx, lat, lng)
 		if err != nil {
 			log.Printf("Error reverse geocoding: %v", err)
-			sendEvent("error", "Failed to resolve location: "+err.Error())
+			job.Fail("Failed to resolve location: " + err.Error())
 			return
 		}
-	} else {
+	default:
 		// Handle City Name (or default)
+		city := query.City
 		if city == "" {
 			city = "San Francisco"
 		}
 
 		// 1. Resolve City
-		formattedCity, _, _, err = h.Maps.GetCityLocation(r.Context(), city)
+		places, err := h.Maps.FindCities(ctx, city)
+		if err == nil && len(places) == 0 {
+			err = fmt.Errorf("city %w", maps.ErrNotFound)
+		}
 		if err != nil {
 			log.Printf("Error resolving location for city '%s': %v", city, err)
-			sendEvent("error", "Failed to find city: "+err.Error())
+			job.Fail("Failed to find city: " + err.Error())
+			return
+		}
+		if len(places) > 1 {
+			log.Printf("City '%s' is ambiguous: %d candidates", city, len(places))
+			jsonData, _ := json.Marshal(newChoices(city, places))
+			sendEvent("choices", string(jsonData))
 			return
 		}
+		place = places[0]
+		lat, lng = place.Lat, place.Lng
 	}
-	
+
+	// A place is cached once, however it was searched for: reuse its
+	// location, or a nearby one, and the name and coordinates it was
+	// generated for.
+	locID := ""
+	if cached := h.findCached(ctx, place); cached != nil {
+		locID = cached.ID
+		place.ID, place.Name = cached.PlaceID, cached.Name
+		if cached.HasCoordinates() {
+			place.Lat, place.Lng = cached.Lat, cached.Lng
+			lat, lng = place.Lat, place.Lng
+		}
+	}
+	formattedCity := place.Name
+
 	log.Printf("Resolved location to: %s", formattedCity)
 	sendEvent("status", "Found location: "+formattedCity)
 
+	// 2. Fetch Forecast. It decides whether a cached image still shows the
+	// right weather and is printed on a new one. On failure the cache falls
+	// back to its TTL and the model does its own search.
+	var forecast *weather.Forecast
+	if h.Weather != nil {
+		sendEvent("status", "Checking the forecast...")
+		forecast, err = h.Weather.GetForecast(ctx, lat, lng)
+		if err != nil {
+			log.Printf("Weather lookup failed for '%s': %v", formattedCity, err)
+			forecast = nil
+		}
+	}
+
 	// --- CACHE CHECK ---
-	locID := sanitizeID(formattedCity)
-	cachedLoc, err := h.DB.GetLocation(r.Context(), locID)
-	if err == nil && cachedLoc != nil && time.Since(cachedLoc.LastUpdated) < 3*time.Hour {
-		log.Printf("Cache Hit for %s", formattedCity)
-		sendEvent("status", "Loading cached forecast...")
-		
-		resp := WeatherResponse{
-			City:     formattedCity,
-			ImageURL: cachedLoc.ImageURL,
-		}
-		jsonData, _ := json.Marshal(resp)
-		sendEvent("result", string(jsonData))
-		
-		if cachedLoc.VideoURL != "" {
-			sendEvent("video", cachedLoc.VideoURL)
+	if locID == "" {
+		locID, err = database.ResolveUserLocationID(ctx, h.DB, formattedCity, place.ID)
+		if err != nil {
+			log.Printf("Failed to check location ID for %s: %v", formattedCity, err)
+			locID = database.UserLocationID(formattedCity, place.ID)
+		}
+	}
+	job.Update(func(j *database.Job) { j.LocationID = locID })
+
+	cachedLoc, fresh := h.checkCache(ctx, locID, lat, lng, forecast)
+	if fresh {
+		h.serveCached(ctx, job, c, cachedLoc, formattedCity, false)
+		return
+	}
+
+	// --- STALE-WHILE-REVALIDATE ---
+	// Show a stale entry that isn't too old right away, while a fresh one is
+	// generated below. Older entries make the client wait, as if uncached.
+	servedStale := false
+	if cachedLoc != nil && h.Cache.ServeStale(cachedLoc, time.Now()) {
+		if !h.serveCached(ctx, job, c, cachedLoc, formattedCity, true) {
+			return
+		}
+		servedStale = true
+	}
+
+	// --- COALESCING ---
+	// Only one job generates a location at a time; everyone else asking for
+	// it meanwhile follows that job's progress.
+	leaderID, err := job.Lead(ctx, locID)
+	if err != nil {
+		log.Printf("Coalescing unavailable for %s: %v", locID, err)
+	} else if leaderID != job.ID() {
+		log.Printf("Job %s following job %s for %s", job.ID(), leaderID, locID)
+		h.followLeader(ctx, job, c, leaderID, servedStale)
+		return
+	} else if cachedLoc, fresh := h.checkCache(ctx, locID, lat, lng, forecast); fresh {
+		// The previous leader finished between the first check and Lead.
+		h.serveCached(ctx, job, c, cachedLoc, formattedCity, false)
+		return
+	}
+
+	if err := c.quota.Take(ctx, ratelimit.Image); err != nil {
+		if servedStale {
+			// Over the image budget the user keeps the stale forecast.
+			sendEvent("error", rateLimitError(err).String())
+			return
 		}
+		failRateLimited(job, err)
 		return
 	}
 
-	// 2. Generate Image
+	// 3. Generate Image
+	job.Update(func(j *database.Job) { j.Stage = database.JobGeneratingImage })
 	sendEvent("status", fmt.Sprintf("Getting a banana image of the weather for %s...", formattedCity))
-	
+
 	// Use formattedCity to ensure the AI gets the full context
-	imgBase64, err := h.GenAI.GenerateImage(r.Context(), formattedCity, "")
+	imgBase64, err := h.Images.GenerateImage(ctx, formattedCity, "", forecast)
 	if err != nil {
 		log.Printf("Error generating image for '%s': %v", formattedCity, err)
-		sendEvent("error", "Failed to generate image: "+err.Error())
+		job.Fail("Failed to generate image: " + err.Error())
 		return
 	}
 	log.Printf("Successfully generated image for: %s", formattedCity)
+	h.recordUsage(ctx, c, database.UsageImages)
+
+	// Upload before sending the result so the event (and the persisted job)
+	// carries a URL rather than megabytes of base64.
+	var gsURI, publicImageURL string
+	if h.Storage != nil {
+		fileName := fmt.Sprintf("image_%d.png", time.Now().UnixNano())
+		gsURI, publicImageURL, err = h.Storage.UploadImage(ctx, imgBase64, fileName)
+		if err != nil {
+			log.Printf("Failed to upload image: %v", err)
+			gsURI, publicImageURL = "", ""
+		}
+	}
+
+	// Cache the stored image (Partial Save) before it is sent, so the
+	// location references it even if no video follows.
+	currentLoc := database.Location{
+		ID:        locID,
+		Name:      formattedCity,
+		CityQuery: formattedCity,
+		PlaceID:   place.ID,
+		Lat:       place.Lat,
+		Lng:       place.Lng,
+		ImageURL:  publicImageURL,
+		Forecast:  forecast,
+		IsPreset:  false,
+		Timezone:  h.timezoneAt(lat, lng, forecast),
+	}
+	if forecast != nil {
+		currentLoc.Fingerprint = forecast.Fingerprint()
+	}
+	if publicImageURL != "" {
+		job.Update(func(j *database.Job) { j.ImageURL = publicImageURL })
+		if err := h.DB.UpsertLocation(ctx, currentLoc); err != nil {
+			log.Printf("Failed to cache %s: %v", locID, err)
+		}
+	}
 
-	// Send Image to Frontend immediately (Base64)
 	resp := WeatherResponse{
-		City:        formattedCity,
-		ImageBase64: imgBase64,
+		City:    formattedCity,
+		Weather: forecast,
+	}
+	if publicImageURL != "" {
+		resp.ImageURL = publicImageURL
+	} else {
+		resp.ImageBase64 = imgBase64
 	}
 	jsonData, _ := json.Marshal(resp)
 	sendEvent("result", string(jsonData))
 
-	// 3. Generate Video (If Storage is available)
-	if h.Storage == nil {
-		log.Printf("Storage service not available, skipping video generation.")
+	// 4. Generate Video (If the image was stored and a video generator is available)
+	if publicImageURL == "" || h.Videos == nil {
+		log.Printf("Storage or video service not available, skipping video generation.")
 		return
 	}
 
 	sendEvent("status", "Preparing for animation...")
 
-	// Upload Image
-	fileName := fmt.Sprintf("image_%d.png", time.Now().UnixNano())
-	gsURI, publicImageURL, err := h.Storage.UploadImage(r.Context(), imgBase64, fileName)
-	if err != nil {
-		log.Printf("Failed to upload image for video gen: %v", err)
+	// Over the video budget the user still gets the image.
+	if err := c.quota.Take(ctx, ratelimit.Video); err != nil {
+		sendEvent("error", rateLimitError(err).String())
 		return
 	}
 
-	// Upsert DB with Image URL (Partial Save)
-	currentLoc := database.Location{
-		ID:        locID,
-		Name:      formattedCity,
-		CityQuery: formattedCity,
-		ImageURL:  publicImageURL,
-		IsPreset:  false,
-	}
-	h.DB.UpsertLocation(r.Context(), currentLoc)
-
+	job.Update(func(j *database.Job) { j.Stage = database.JobGeneratingVideo })
 	sendEvent("status", "Animating (Veo 3.1)... this may take a minute.")
 
-	// Call Veo
-	prompt := "The camera moves in parallax as the elements in the image move naturally, while the forecast data—the bold title remain fixed."
-	videoGsURI, err := h.GenAI.GenerateVideo(r.Context(), gsURI, prompt)
-	if err != nil {
+	var publicVideoURL string
+	if h.VideoPool == nil {
+		publicVideoURL, err = h.generateVideo(ctx, job, currentLoc, gsURI)
+	} else {
+		// The task writes result before done is closed; it is read only after.
+		var result struct {
+			url string
+			err error
+		}
+		done, submitErr := h.VideoPool.Submit(func(ctx context.Context) {
+			// Stays set if generateVideo panics (the pool recovers).
+			result.err = errVideoPanicked
+			result.url, result.err = h.generateVideo(ctx, job, currentLoc, gsURI)
+		})
+		if submitErr != nil {
+			err = submitErr
+		} else {
+			select {
+			case <-done:
+				publicVideoURL, err = result.url, result.err
+			case <-ctx.Done():
+				// Server shutting down; the worker sees the same cancellation.
+				return
+			}
+		}
+	}
+	if errors.Is(err, jobs.ErrPoolFull) {
+		log.Printf("Video pool full, skipping video for %s", formattedCity)
+		sendEvent("error", "Too many videos in progress right now. Enjoy the image!")
+		return
+	} else if err != nil {
 		log.Printf("Veo generation failed: %v", err)
 		sendEvent("error", "Video generation failed (Beta). Enjoy the image!")
 		return
 	}
 
-	sendEvent("status", "Finalizing video...")
-
-	// Convert gs://bucket/path to https://storage.googleapis.com/bucket/path
-	publicVideoURL := "https://storage.googleapis.com/" + videoGsURI[5:] // Strip gs://
-
-	log.Printf("Video available at: %s", publicVideoURL)
+	job.Update(func(j *database.Job) { j.VideoURL = publicVideoURL })
 	sendEvent("video", publicVideoURL)
-
-	// Final Upsert with Video URL
-	currentLoc.VideoURL = publicVideoURL
-	h.DB.UpsertLocation(r.Context(), currentLoc)
+	h.recordUsage(ctx, c, database.UsageVideos)
 }
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/gazetteer"
+	"banana-weather/pkg/genai"
+	"banana-weather/pkg/jobs"
+	"banana-weather/pkg/maps"
+	"banana-weather/pkg/storage"
+	"banana-weather/pkg/weather"
+)
+
+func TestParseEventID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantJob string
+		wantSeq int
+	}{
+		{"abc123:4", "abc123", 4},
+		{"abc123:0", "abc123", 0},
+		{"", "", 0},
+		{"abc123", "", 0},
+		{"abc123:", "", 0},
+		{"abc123:x", "", 0},
+		{"abc123:-1", "", 0},
+		{"abc123:1:2", "", 0},
+	}
+	for _, tt := range tests {
+		job, seq := parseEventID(tt.id)
+		if job != tt.wantJob || seq != tt.wantSeq {
+			t.Errorf("parseEventID(%q) = %q, %d; want %q, %d", tt.id, job, seq, tt.wantJob, tt.wantSeq)
+		}
+	}
+}
+
+// newDevHandler wires the offline fakes that `go run . --dev` uses.
+func newDevHandler(t *testing.T) *Handler {
+	t.Helper()
+	places, err := gazetteer.NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	media, err := storage.NewLocalService(t.TempDir(), "http://media.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	placeholder := genai.NewPlaceholderService(media)
+	store := database.NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &Handler{
+		Maps:      places,
+		Images:    placeholder,
+		Videos:    placeholder,
+		Storage:   media,
+		DB:        store,
+		Weather:   weather.NewStub(places),
+		Jobs:      jobs.NewManager(ctx, store),
+		Timezones: places,
+	}
+}
+
+type sseEvent struct {
+	id, event, data string
+}
+
+// readEvents reads SSE events until the stream ends.
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var e sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			e.id = value
+		case "event":
+			e.event = value
+		case "data":
+			e.data = value
+		case "":
+			events = append(events, e)
+			e = sseEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	return events
+}
+
+func TestWeatherStream(t *testing.T) {
+	h := newDevHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleGetWeather))
+	defer srv.Close()
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Get(srv.URL + "/api/weather?city=Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	events := readEvents(t, resp)
+
+	byName := make(map[string]sseEvent)
+	for _, e := range events {
+		byName[e.event] = e
+	}
+	if len(events) == 0 || events[0].event != "job" {
+		t.Fatalf("first event is not job: %+v", events)
+	}
+	jobID := events[0].data
+	last := events[len(events)-1]
+	if last.event != "done" || last.data != jobID {
+		t.Errorf("last event = %+v, want done with the job ID", last)
+	}
+	if e, ok := byName["error"]; ok {
+		t.Errorf("unexpected error event: %s", e.data)
+	}
+
+	var result struct {
+		City     string            `json:"city"`
+		ImageURL string            `json:"image_url"`
+		Weather  *weather.Forecast `json:"weather"`
+	}
+	if err := json.Unmarshal([]byte(byName["result"].data), &result); err != nil {
+		t.Fatalf("bad result event %q: %v", byName["result"].data, err)
+	}
+	if !strings.Contains(result.City, "Tokyo") || !strings.HasPrefix(result.ImageURL, "http://media.test/") || result.Weather == nil {
+		t.Errorf("result = %+v, want Tokyo with an image and a forecast", result)
+	}
+	if video := byName["video"].data; !strings.HasPrefix(video, "http://media.test/") {
+		t.Errorf("video event = %q, want a media URL", video)
+	}
+	for i, e := range events {
+		if want := jobID + ":" + strconv.Itoa(i+1); e.id != want {
+			t.Errorf("event %d (%s) has ID %q, want %q", i, e.event, e.id, want)
+		}
+	}
+
+	// Resuming after the last event finds nothing left to send.
+	req, _ := http.NewRequest("GET", srv.URL+"/api/weather", nil)
+	req.Header.Set("Last-Event-ID", last.id)
+	resumed, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Body.Close()
+	if resumed.StatusCode != http.StatusNoContent {
+		t.Errorf("resuming after done: status %d, want %d", resumed.StatusCode, http.StatusNoContent)
+	}
+
+	// Resuming midway replays the rest.
+	req.Header.Set("Last-Event-ID", events[len(events)-3].id)
+	replayed, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.Body.Close()
+	if got := readEvents(t, replayed); len(got) != 2 || got[1].event != "done" {
+		t.Errorf("replayed %+v, want the last two events", got)
+	}
+}
+
+func TestWeatherStreamRejectsBadRequests(t *testing.T) {
+	h := newDevHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleGetWeather))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/weather?lat=91&lng=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := readEvents(t, resp)
+	if len(events) != 1 || events[0].event != "error" {
+		t.Fatalf("events = %+v, want a single error", events)
+	}
+	var e ErrorEvent
+	if err := json.Unmarshal([]byte(events[0].data), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Code != "coordinates_out_of_range" || e.Param != "lat" {
+		t.Errorf("error = %+v, want coordinates_out_of_range for lat", e)
+	}
+}
+
+// failingVideos is a VideoGenerator whose operations fail at once, or panic.
+type failingVideos struct{ panics bool }
+
+func (v failingVideos) StartVideo(ctx context.Context, inputImageURI, prompt string) (string, error) {
+	if v.panics {
+		panic("video model exploded")
+	}
+	return "", errors.New("video model unavailable")
+}
+
+func (v failingVideos) AwaitVideo(ctx context.Context, opName string) (string, error) {
+	return "", errors.New("video model unavailable")
+}
+
+func TestWeatherStreamVideoFailure(t *testing.T) {
+	for _, panics := range []bool{false, true} {
+		h := newDevHandler(t)
+		h.Videos = failingVideos{panics: panics}
+		h.VideoPool = jobs.NewPool(t.Context(), 1, 1, time.Minute)
+		srv := httptest.NewServer(http.HandlerFunc(h.HandleGetWeather))
+
+		resp, err := http.Get(srv.URL + "/api/weather?city=Lima")
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := readEvents(t, resp)
+		resp.Body.Close()
+		srv.Close()
+
+		var names []string
+		for _, e := range events {
+			names = append(names, e.event)
+			if e.event == "video" {
+				t.Errorf("panics=%v: got video event %q for a failed generation", panics, e.data)
+			}
+		}
+		if len(events) < 3 || events[len(events)-2].event != "error" || events[len(events)-1].event != "done" {
+			t.Errorf("panics=%v: events %v, want an error before done", panics, names)
+		}
+	}
+}
+
+func TestResumePendingVideos(t *testing.T) {
+	h := newDevHandler(t)
+	ctx := context.Background()
+	started := time.Now().Add(-time.Minute)
+	abandoned := time.Now().Add(-2 * maxVideoOpAge)
+	for _, loc := range []database.Location{
+		{ID: "tokyo", Name: "Tokyo", ImageURL: "http://media.test/tokyo.png", VideoOpName: "placeholder:tokyo.png", VideoOpStartedAt: &started},
+		{ID: "rome", Name: "Rome", ImageURL: "http://media.test/rome.png", VideoOpName: "placeholder:rome.png", VideoOpStartedAt: &started, IsPreset: true},
+		{ID: "lima", Name: "Lima", ImageURL: "http://media.test/lima.png", VideoOpName: "placeholder:lima.png", VideoOpStartedAt: &abandoned},
+	} {
+		if err := h.DB.UpsertLocation(ctx, loc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.ResumePendingVideos(ctx)
+
+	for _, tt := range []struct {
+		ns        database.Namespace
+		id        string
+		wantVideo bool
+	}{
+		{database.UserNamespace, "tokyo", true},
+		{database.PresetNamespace, "rome", true},
+		{database.UserNamespace, "lima", false},
+	} {
+		loc, err := h.DB.GetLocation(ctx, tt.ns, tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loc.VideoOpName != "" || (loc.VideoURL != "") != tt.wantVideo {
+			t.Errorf("%s: video %q, operation %q; want video %v and no operation", tt.id, loc.VideoURL, loc.VideoOpName, tt.wantVideo)
+		}
+	}
+}
+
+// noCities is a Geocoder that finds nothing without saying so.
+type noCities struct{ maps.Geocoder }
+
+func (noCities) FindCities(ctx context.Context, query string) ([]maps.Place, error) {
+	return []maps.Place{}, nil
+}
+
+func TestWeatherStreamNoCities(t *testing.T) {
+	h := newDevHandler(t)
+	h.Maps = noCities{h.Maps}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleGetWeather))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/weather?city=Atlantis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := readEvents(t, resp)
+	if len(events) < 2 || events[len(events)-2].event != "error" || !strings.Contains(events[len(events)-2].data, "not found") {
+		t.Errorf("events %+v, want a not found error", events)
+	}
+}
+
+func TestWeatherStreamCachesImageWithoutVideos(t *testing.T) {
+	h := newDevHandler(t)
+	h.Videos = nil
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleGetWeather))
+	defer srv.Close()
+
+	var imageURLs []string
+	for range 2 {
+		resp, err := http.Get(srv.URL + "/api/weather?city=Tokyo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range readEvents(t, resp) {
+			if e.event == "video" {
+				t.Errorf("video event %q without a video generator", e.data)
+			}
+			if e.event == "result" {
+				var result WeatherResponse
+				json.Unmarshal([]byte(e.data), &result)
+				imageURLs = append(imageURLs, result.ImageURL)
+			}
+		}
+		resp.Body.Close()
+	}
+	if len(imageURLs) != 2 || imageURLs[0] == "" || imageURLs[1] != imageURLs[0] {
+		t.Errorf("image URLs %q, want the first image served again from cache", imageURLs)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"banana-weather/pkg/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// JobStatus is the response of GET /api/jobs/{id}.
+type JobStatus struct {
+	ID          string    `json:"id"`
+	City        string    `json:"city"`
+	LocationID  string    `json:"location_id,omitempty"`
+	Stage       string    `json:"stage"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	VideoURL    string    `json:"video_url,omitempty"`
+	VideoOpName string    `json:"video_op_name,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	LastEventID string    `json:"last_event_id,omitempty"` // Pass as Last-Event-ID to resume the stream
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (h *Handler) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	id := chi.URLParam(r, "id")
+	job, err := h.Jobs.Get(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get job %s: %v", id, err)
+		http.Error(w, "Failed to fetch job", http.StatusInternalServerError)
+		return
+	}
+
+	status := JobStatus{
+		ID:          job.ID,
+		City:        job.City,
+		LocationID:  job.LocationID,
+		Stage:       job.Stage,
+		ImageURL:    job.ImageURL,
+		VideoURL:    job.VideoURL,
+		VideoOpName: job.VideoOpName,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+	if n := len(job.Events); n > 0 {
+		status.LastEventID = job.ID + ":" + strconv.Itoa(job.Events[n-1].ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"banana-weather/pkg/auth"
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/ratelimit"
+)
+
+// caller is whoever started a job, for rate limiting and usage accounting.
+type caller struct {
+	quota *ratelimit.Quota
+	key   *database.APIKey // nil for anonymous requests
+}
+
+// recordUsage counts one use of kind (database.Usage*) against the caller's
+// API key, if any.
+func (h *Handler) recordUsage(ctx context.Context, c caller, kind string) {
+	if c.key == nil || h.Keys == nil {
+		return
+	}
+	if err := h.Keys.RecordUsage(ctx, c.key.ID, kind); err != nil {
+		log.Printf("Failed to record %s usage for key %s: %v", kind, c.key.Prefix, err)
+	}
+}
+
+// UsageResponse is the response of GET /api/usage.
+type UsageResponse struct {
+	Name       string           `json:"name"`
+	Prefix     string           `json:"prefix"`
+	Usage      map[string]int64 `json:"usage"`
+	CreatedAt  time.Time        `json:"created_at"`
+	LastUsedAt time.Time        `json:"last_used_at,omitempty"`
+}
+
+// HandleGetUsage reports the usage counters of the request's API key.
+func (h *Handler) HandleGetUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	// The middleware has just loaded the key, counters included.
+	key := auth.FromContext(r.Context())
+	if key == nil {
+		http.Error(w, "API key required", http.StatusUnauthorized)
+		return
+	}
+
+	usage := map[string]int64{
+		database.UsageImages:    0,
+		database.UsageVideos:    0,
+		database.UsageCacheHits: 0,
+	}
+	for kind, n := range key.Usage {
+		usage[kind] = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UsageResponse{
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Usage:      usage,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+	})
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxCityLen caps city queries in runes. The longest place names run to
+	// about 85 letters.
+	maxCityLen = 100
+	// maxCityWords caps the words of a city query: enough for
+	// "Santa Cruz de la Sierra, Bolivia", too few for instructions.
+	maxCityWords = 12
+	// maxPlaceIDLen caps place IDs. Maps IDs are usually under 30
+	// characters, though some run to a few hundred.
+	maxPlaceIDLen = 512
+)
+
+// coordinatePattern is a plain decimal number, e.g. "-33.8688". It leaves
+// out what strconv.ParseFloat also accepts: "NaN", "Inf", exponents, hex.
+// Shortest float formatting (JavaScript's, Dart's toString) uses up to 17
+// significant digits after as many as six leading zeros, e.g. 0.0000012...
+var coordinatePattern = regexp.MustCompile(`^[+-]?[0-9]{1,3}(\.[0-9]{1,23})?$`)
+
+// placePunctuation is the punctuation found in place names, e.g. "St. John's",
+// "Baden-Baden", "Washington, D.C.", "Bar-le-Duc (Meuse)", "Pikes Peak/Colorado".
+const placePunctuation = ",.'’-()&/"
+
+// joiners are the zero-width (non-)joiners some scripts spell names with,
+// e.g. Persian. Other invisible characters are rejected.
+const joiners = "\u200c\u200d"
+
+// injectionPattern matches phrases that address the image model rather than
+// name a place.
+var injectionPattern = regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b.*\b(previous|above|prior|all|instructions?|rules)\b` +
+	`|\b(system|developer)\s+prompt\b|\byou\s+are\s+now\b|\bact\s+as\b|\bpretend\s+to\b|\bnew\s+instructions?\b|\bjailbreak\b`)
+
+// weatherQuery is a validated /api/weather request: a place ID, coordinates
+// or a city name, in that order of precedence.
+type weatherQuery struct {
+	City      string
+	PlaceID   string
+	Lat, Lng  float64
+	HasCoords bool
+}
+
+// String describes the query for logs and job records.
+func (q weatherQuery) String() string {
+	switch {
+	case q.PlaceID != "":
+		return q.PlaceID
+	case q.HasCoords:
+		return strconv.FormatFloat(q.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(q.Lng, 'f', -1, 64)
+	default:
+		return q.City
+	}
+}
+
+// parseWeatherQuery validates the city, lat, lng and place_id parameters.
+// Rejected requests get an ErrorEvent with one of the codes
+// "invalid_coordinates", "coordinates_out_of_range", "invalid_place_id",
+// "invalid_city", "city_too_long" or "suspicious_city".
+func parseWeatherQuery(params url.Values) (weatherQuery, *ErrorEvent) {
+	var q weatherQuery
+
+	if placeID := params.Get("place_id"); placeID != "" {
+		if len(placeID) > maxPlaceIDLen || !utf8.ValidString(placeID) || strings.ContainsFunc(placeID, isHidden) {
+			return q, &ErrorEvent{Code: "invalid_place_id", Message: "That place ID isn't valid.", Param: "place_id"}
+		}
+		q.PlaceID = placeID
+	}
+
+	latStr, lngStr := params.Get("lat"), params.Get("lng")
+	if latStr != "" || lngStr != "" {
+		if latStr == "" {
+			return q, &ErrorEvent{Code: "invalid_coordinates", Message: "Both lat and lng are required.", Param: "lat"}
+		}
+		if lngStr == "" {
+			return q, &ErrorEvent{Code: "invalid_coordinates", Message: "Both lat and lng are required.", Param: "lng"}
+		}
+		const badCoordinate = "Coordinates must be decimal numbers, e.g. lat=37.77&lng=-122.42."
+		lat, ok := parseCoordinate(latStr)
+		if !ok {
+			return q, &ErrorEvent{Code: "invalid_coordinates", Message: badCoordinate, Param: "lat"}
+		}
+		lng, ok := parseCoordinate(lngStr)
+		if !ok {
+			return q, &ErrorEvent{Code: "invalid_coordinates", Message: badCoordinate, Param: "lng"}
+		}
+		if lat < -90 || lat > 90 {
+			return q, &ErrorEvent{Code: "coordinates_out_of_range", Message: "Latitude must be between -90 and 90.", Param: "lat"}
+		}
+		if lng < -180 || lng > 180 {
+			return q, &ErrorEvent{Code: "coordinates_out_of_range", Message: "Longitude must be between -180 and 180.", Param: "lng"}
+		}
+		q.Lat, q.Lng, q.HasCoords = lat, lng, true
+	}
+
+	city, e := validateCity(params.Get("city"))
+	if e != nil {
+		e.Param = "city"
+		return q, e
+	}
+	q.City = city
+	return q, nil
+}
+
+// validateCity returns city with surrounding space trimmed, or an ErrorEvent
+// if it can't be a place name. An empty city is valid. Callers set the
+// ErrorEvent's Param.
+func validateCity(city string) (string, *ErrorEvent) {
+	city = strings.TrimSpace(city)
+	switch {
+	case !utf8.ValidString(city) || strings.ContainsFunc(city, isHidden):
+		return "", &ErrorEvent{Code: "invalid_city", Message: "City names can't contain control characters."}
+	case utf8.RuneCountInString(city) > maxCityLen:
+		return "", &ErrorEvent{Code: "city_too_long", Message: "That city name is too long."}
+	case strings.ContainsFunc(city, func(r rune) bool { return !isPlaceNameRune(r) }):
+		return "", &ErrorEvent{Code: "invalid_city", Message: "City names can only contain letters, digits, spaces and basic punctuation."}
+	case len(strings.Fields(city)) > maxCityWords || injectionPattern.MatchString(city):
+		return "", &ErrorEvent{Code: "suspicious_city", Message: "That doesn't look like a city name."}
+	}
+	return city, nil
+}
+
+// parseCoordinate parses a plain decimal coordinate.
+func parseCoordinate(s string) (float64, bool) {
+	if !coordinatePattern.MatchString(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// isHidden reports whether r is a control or invisible formatting character
+// (e.g. a zero-width space or a bidirectional override).
+func isHidden(r rune) bool {
+	return unicode.IsControl(r) || (unicode.Is(unicode.Cf, r) && !strings.ContainsRune(joiners, r))
+}
+
+// isPlaceNameRune reports whether r may appear in a place name.
+func isPlaceNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r) || r == ' ' ||
+		strings.ContainsRune(placePunctuation, r) || strings.ContainsRune(joiners, r)
+}
+
+// writeError responds with status and an ErrorEvent as JSON.
+func writeError(w http.ResponseWriter, status int, e ErrorEvent) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
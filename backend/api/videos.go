@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/genai"
+	"banana-weather/pkg/jobs"
+)
+
+// Veo operations older than this are assumed to be gone and are no longer
+// resumed.
+const maxVideoOpAge = 24 * time.Hour
+
+// errVideoPanicked is the error of a video generation that panicked.
+var errVideoPanicked = errors.New("video generation panicked")
+
+// generateVideo animates the stored image and saves the video URL on loc. It
+// does not report progress, so it can finish after the job's followers leave.
+// The Veo operation is recorded on the job and on the location first, so
+// that ResumePendingVideos can finish it if this process dies.
+func (h *Handler) generateVideo(ctx context.Context, job *jobs.Handle, loc database.Location, gsURI string) (string, error) {
+	// Call Veo
+	opName, err := h.Videos.StartVideo(ctx, gsURI, genai.VideoPrompt)
+	if err != nil {
+		return "", err
+	}
+	job.Update(func(j *database.Job) { j.VideoOpName = opName })
+
+	now := time.Now()
+	loc.VideoOpName = opName
+	loc.VideoOpStartedAt = &now
+	err = h.DB.UpdateLocation(ctx, loc.Namespace(), loc.ID, func(current *database.Location) bool {
+		current.VideoOpName, current.VideoOpStartedAt = loc.VideoOpName, loc.VideoOpStartedAt
+		return true
+	})
+	if err != nil {
+		log.Printf("Failed to record video operation for %s: %v", loc.ID, err)
+	}
+	return h.awaitVideo(ctx, loc)
+}
+
+// awaitVideo waits for loc's pending Veo operation and saves the result.
+func (h *Handler) awaitVideo(ctx context.Context, loc database.Location) (string, error) {
+	videoURI, err := h.Videos.AwaitVideo(ctx, loc.VideoOpName)
+	if err != nil {
+		// Only forget the operation if Veo reported a failure; if we merely
+		// ran out of time it may still be resumed.
+		if ctx.Err() == nil {
+			h.saveVideo(ctx, loc, "")
+		}
+		return "", err
+	}
+
+	// Convert gs://bucket/path to https://storage.googleapis.com/bucket/path
+	publicVideoURL := h.Storage.PublicURL(videoURI)
+	log.Printf("Video available at: %s", publicVideoURL)
+
+	h.saveVideo(ctx, loc, publicVideoURL)
+	return publicVideoURL, nil
+}
+
+// saveVideo stores videoURL (if any) on loc and clears its pending operation,
+// unless the location has since been regenerated with a different one. Only
+// those fields are written, so edits made meanwhile (e.g. by an admin) are
+// kept, and LastUpdated still dates the image.
+func (h *Handler) saveVideo(ctx context.Context, loc database.Location, videoURL string) {
+	regenerated := false
+	err := h.DB.UpdateLocation(ctx, loc.Namespace(), loc.ID, func(current *database.Location) bool {
+		regenerated = current.VideoOpName != loc.VideoOpName
+		if regenerated {
+			return false
+		}
+		if videoURL != "" {
+			current.VideoURL = videoURL
+		}
+		current.VideoOpName = ""
+		current.VideoOpStartedAt = nil
+		return true
+	})
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		log.Printf("Location %s was deleted, discarding video from %s", loc.ID, loc.VideoOpName)
+	case err != nil:
+		log.Printf("Failed to save video for %s: %v", loc.ID, err)
+	case regenerated:
+		log.Printf("Location %s was regenerated, discarding video from %s", loc.ID, loc.VideoOpName)
+	}
+}
+
+// ResumePendingVideos finishes Veo operations that were started by a previous
+// process (e.g. before a deploy) and fills in the locations' VideoURL. It is
+// meant to run once in the background at startup. If several instances start
+// together they may poll the same operation; the result is the same.
+func (h *Handler) ResumePendingVideos(ctx context.Context) {
+	if h.Videos == nil || h.Storage == nil {
+		return
+	}
+	pending, err := h.DB.GetPendingVideos(ctx)
+	if err != nil {
+		log.Printf("Failed to list pending videos: %v", err)
+		return
+	}
+	if len(pending) > 0 {
+		log.Printf("Resuming %d pending video(s)", len(pending))
+	}
+
+	var inflight []<-chan struct{}
+	for _, loc := range pending {
+		if loc.VideoOpStartedAt == nil || time.Since(*loc.VideoOpStartedAt) > maxVideoOpAge {
+			log.Printf("Giving up on stale video operation %s for %s", loc.VideoOpName, loc.ID)
+			h.saveVideo(ctx, loc, "")
+			continue
+		}
+
+		resume := func(ctx context.Context) {
+			if _, err := h.awaitVideo(ctx, loc); err != nil {
+				log.Printf("Failed to resume video for %s: %v", loc.ID, err)
+			}
+		}
+		if h.VideoPool == nil {
+			resume(ctx)
+			continue
+		}
+
+		// Leave room for live requests: when the pool is full, wait for one
+		// of our own tasks (or a few seconds) before trying again.
+		for {
+			done, err := h.VideoPool.Submit(resume)
+			if err == nil {
+				inflight = append(inflight, done)
+				break
+			}
+			if !errors.Is(err, jobs.ErrPoolFull) {
+				log.Printf("Failed to resume video for %s: %v", loc.ID, err)
+				break
+			}
+			var wait <-chan struct{}
+			if len(inflight) > 0 {
+				wait, inflight = inflight[0], inflight[1:]
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-wait:
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+}
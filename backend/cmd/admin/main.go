@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/genai"
+	"banana-weather/pkg/presets"
+	"banana-weather/pkg/storage"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/joho/godotenv"
+)
+
+// admin is a terminal console for the location store: browse presets and
+// cached user locations, fix their metadata and regenerate their media.
+//
+//	go run ./cmd/admin
+//	go run ./cmd/admin -dev   # placeholder media instead of Gemini/Veo
+func main() {
+	// Load .env
+	_ = godotenv.Load("../../.env")
+	_ = godotenv.Load("../.env")
+	_ = godotenv.Load(".env")
+
+	devMode := flag.Bool("dev", false, "Generate placeholder media locally instead of calling Gemini and Veo")
+	logPath := flag.String("log", "admin.log", "File to write logs to while the console is open")
+	flag.Parse()
+
+	// The console owns the terminal, so logs go to a file.
+	logFile, err := tea.LogToFile(*logPath, "")
+	if err != nil {
+		log.Fatalf("Failed to open log file: %v", err)
+	}
+	defer logFile.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dbService, err := database.NewStore(ctx)
+	if err != nil {
+		fatal("Failed to init DB: %v", err)
+	}
+	defer dbService.Close()
+
+	var gen *presets.Generator
+	if *devMode {
+		gen, err = newDevGenerator(dbService)
+	} else {
+		gen, err = newGenerator(ctx, dbService)
+	}
+	if err != nil {
+		fatal("%v", err)
+	}
+
+	// Staleness is shown as the server sees it.
+	cache, err := database.NewCachePolicyFromEnv()
+	if err != nil {
+		fatal("%v", err)
+	}
+
+	p := tea.NewProgram(newModel(ctx, dbService, gen, cache), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		fatal("Console failed: %v", err)
+	}
+}
+
+// newGenerator wires Gemini, Veo and the configured blob store, like
+// cmd/generate_preset.
+func newGenerator(ctx context.Context, db database.LocationStore) (*presets.Generator, error) {
+	genaiService, err := genai.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init GenAI: %w", err)
+	}
+	storageService, err := storage.NewBlobStore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init Storage: %w", err)
+	}
+	genaiService.SetVideoStore(storageService)
+
+	return &presets.Generator{
+		Images:  genaiService,
+		Videos:  genaiService,
+		Storage: storageService,
+		DB:      db,
+	}, nil
+}
+
+// newDevGenerator writes placeholder media to MEDIA_DIR, the same directory
+// the dev server serves from /media.
+func newDevGenerator(db database.LocationStore) (*presets.Generator, error) {
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = filepath.Join(os.TempDir(), "banana-weather-media")
+	}
+	mediaBaseURL := os.Getenv("MEDIA_BASE_URL")
+	if mediaBaseURL == "" {
+		mediaBaseURL = "http://localhost:8080/media"
+	}
+	localMedia, err := storage.NewLocalService(mediaDir, mediaBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init local storage: %w", err)
+	}
+
+	placeholder := genai.NewPlaceholderService(localMedia)
+	return &presets.Generator{
+		Images:  placeholder,
+		Videos:  placeholder,
+		Storage: localMedia,
+		DB:      db,
+	}, nil
+}
+
+// fatal reports err on the terminal as well as in the log file.
+func fatal(format string, args ...any) {
+	log.Printf(format, args...)
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/presets"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("220"))
+	helpStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	labelStyle = lipgloss.NewStyle().Width(12)
+)
+
+type screen int
+
+const (
+	listScreen     screen = iota
+	editScreen            // Editing the metadata of one location
+	confirmScreen         // Confirming a (paid) generation
+	generateScreen        // Generation in progress or finished
+)
+
+// choice is a three-way filter: everything, only matches, or only
+// non-matches.
+type choice int
+
+const (
+	choiceAll choice = iota
+	choiceYes
+	choiceNo
+)
+
+func (c choice) next() choice { return (c + 1) % 3 }
+
+func (c choice) allows(v bool) bool {
+	return c == choiceAll || (c == choiceYes) == v
+}
+
+func (c choice) label(yes, no string) string {
+	switch c {
+	case choiceYes:
+		return yes
+	case choiceNo:
+		return no
+	}
+	return "all"
+}
+
+// Messages
+type (
+	locationsMsg struct {
+		locations []database.Location
+		err       error
+	}
+	savedMsg struct {
+		loc database.Location
+		err error
+	}
+	progressMsg  string
+	generatedMsg struct {
+		loc *database.Location
+		err error
+	}
+)
+
+// edit form fields, in tab order
+const (
+	fieldName = iota
+	fieldCategory
+	fieldCityQuery
+)
+
+var fieldLabels = []string{"Name", "Category", "City query"}
+
+type model struct {
+	ctx   context.Context
+	db    database.LocationStore
+	gen   *presets.Generator
+	cache database.CachePolicy
+
+	screen screen
+	status string
+	err    error
+
+	// List
+	locations []database.Location // Everything in the store
+	shown     []database.Location // After filtering, in table order
+	table     table.Model
+	category  string // "" for all categories
+	preset    choice
+	stale     choice
+
+	// Edit
+	editing database.Location
+	inputs  []textinput.Model
+	focus   int
+
+	// Generate
+	target    database.Location
+	spinner   spinner.Model
+	progress  []string
+	started   time.Time
+	finished  time.Time
+	cancel    context.CancelFunc
+	events    chan tea.Msg
+	generated *database.Location
+}
+
+func newModel(ctx context.Context, db database.LocationStore, gen *presets.Generator, cache database.CachePolicy) model {
+	t := table.New(
+		table.WithColumns([]table.Column{
+			{Title: "ID", Width: 28},
+			{Title: "Name", Width: 28},
+			{Title: "Category", Width: 14},
+			{Title: "Preset", Width: 6},
+			{Title: "Video", Width: 7},
+			{Title: "Updated", Width: 10},
+		}),
+		table.WithFocused(true),
+		table.WithHeight(20),
+	)
+	styles := table.DefaultStyles()
+	styles.Selected = styles.Selected.Foreground(lipgloss.Color("0")).Background(lipgloss.Color("220"))
+	t.SetStyles(styles)
+
+	inputs := make([]textinput.Model, len(fieldLabels))
+	for i := range inputs {
+		inputs[i] = textinput.New()
+		inputs[i].CharLimit = 200
+		inputs[i].Width = 50
+		inputs[i].Prompt = ""
+	}
+
+	return model{
+		ctx:     ctx,
+		db:      db,
+		gen:     gen,
+		cache:   cache,
+		table:   t,
+		inputs:  inputs,
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot)),
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return m.loadLocations()
+}
+
+func (m model) loadLocations() tea.Cmd {
+	return func() tea.Msg {
+		locations, err := m.db.ListLocations(m.ctx)
+		return locationsMsg{locations: locations, err: err}
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Title, filter line, status and help around the table
+		m.table.SetHeight(max(msg.Height-8, 5))
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			if m.cancel != nil {
+				m.cancel()
+			}
+			return m, tea.Quit
+		}
+
+	case locationsMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("failed to list locations: %w", msg.err)
+			return m, nil
+		}
+		m.locations = msg.locations
+		m.err = nil
+		m.applyFilters()
+		return m, nil
+
+	case savedMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("failed to save %s: %w", msg.loc.ID, msg.err)
+			return m, nil
+		}
+		m.err = nil
+		m.status = "Saved " + msg.loc.ID
+		m.screen = listScreen
+		return m, m.loadLocations()
+
+	case progressMsg:
+		m.progress = append(m.progress, string(msg))
+		return m, waitForEvent(m.events)
+
+	case generatedMsg:
+		m.cancel()
+		m.cancel, m.events = nil, nil
+		m.finished = time.Now()
+		m.generated = msg.loc
+		m.err = msg.err
+		if msg.err != nil {
+			log.Printf("Admin console: generation of %s failed: %v", m.target.ID, msg.err)
+		}
+		return m, m.loadLocations()
+
+	case spinner.TickMsg:
+		if m.cancel == nil {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	switch m.screen {
+	case editScreen:
+		return m.updateEdit(msg)
+	case confirmScreen:
+		return m.updateConfirm(msg)
+	case generateScreen:
+		return m.updateGenerate(msg)
+	}
+	return m.updateList(msg)
+}
+
+// -- List --
+
+func (m model) updateList(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "q":
+			return m, tea.Quit
+		case "r":
+			m.status = "Reloaded"
+			return m, m.loadLocations()
+		case "c":
+			m.category = m.nextCategory()
+			m.applyFilters()
+			return m, nil
+		case "p":
+			m.preset = m.preset.next()
+			m.applyFilters()
+			return m, nil
+		case "s":
+			m.stale = m.stale.next()
+			m.applyFilters()
+			return m, nil
+		case "e", "enter":
+			if loc, ok := m.selected(); ok {
+				return m.startEdit(loc)
+			}
+			return m, nil
+		case "g":
+			if loc, ok := m.selected(); ok {
+				m.target = loc
+				m.screen = confirmScreen
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m model) selected() (database.Location, bool) {
+	i := m.table.Cursor()
+	if i < 0 || i >= len(m.shown) {
+		return database.Location{}, false
+	}
+	return m.shown[i], true
+}
+
+// nextCategory cycles through "" (all) and every category in the store.
+func (m model) nextCategory() string {
+	var categories []string
+	for _, loc := range m.locations {
+		if loc.Category != "" && !slices.Contains(categories, loc.Category) {
+			categories = append(categories, loc.Category)
+		}
+	}
+	slices.Sort(categories)
+	categories = append([]string{""}, categories...)
+
+	i := slices.Index(categories, m.category)
+	return categories[(i+1)%len(categories)]
+}
+
+func (m *model) applyFilters() {
+	now := time.Now()
+	m.shown = nil
+	for _, loc := range m.locations {
+		if m.category != "" && loc.Category != m.category {
+			continue
+		}
+		if !m.preset.allows(loc.IsPreset) || !m.stale.allows(m.cache.Stale(&loc, now)) {
+			continue
+		}
+		m.shown = append(m.shown, loc)
+	}
+
+	rows := make([]table.Row, len(m.shown))
+	for i, loc := range m.shown {
+		preset := ""
+		if loc.IsPreset {
+			preset = "yes"
+		}
+		video := "-"
+		switch {
+		case loc.VideoOpName != "":
+			video = "pending"
+		case loc.VideoURL != "":
+			video = "yes"
+		}
+		updated := age(now, loc.LastUpdated)
+		if m.cache.Stale(&loc, now) {
+			updated += " *"
+		}
+		rows[i] = table.Row{loc.ID, loc.Name, loc.Category, preset, video, updated}
+	}
+	// Keep the selection where possible. The table doesn't clamp its
+	// cursor or scroll offset when rows shrink, so go through GotoTop.
+	cursor := min(m.table.Cursor(), len(rows)-1)
+	m.table.SetRows(rows)
+	m.table.GotoTop()
+	if cursor > 0 {
+		m.table.MoveDown(cursor)
+	}
+}
+
+func (m model) viewList() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Banana Weather — Locations") + "\n")
+
+	category := m.category
+	if category == "" {
+		category = "all"
+	}
+	fmt.Fprintf(&b, "Category: %s  Preset: %s  Staleness: %s  (%d of %d)\n",
+		category,
+		m.preset.label("presets", "user"),
+		m.stale.label("stale", "fresh"),
+		len(m.shown), len(m.locations))
+
+	b.WriteString(m.table.View() + "\n")
+	b.WriteString(m.statusLine() + "\n")
+	b.WriteString(helpStyle.Render("↑/↓ move • c category • p preset • s staleness • e edit • g generate • r reload • q quit"))
+	return b.String()
+}
+
+// age formats how long ago t was, e.g. "5m", "3h" or "12d". Stale entries
+// are marked with "*" by the caller.
+func age(now, t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+func (m model) statusLine() string {
+	switch {
+	case m.err != nil:
+		return errorStyle.Render("Error: " + m.err.Error())
+	case m.locations == nil:
+		return "Loading locations..."
+	}
+	return m.status
+}
+
+// -- Edit --
+
+func (m model) startEdit(loc database.Location) (tea.Model, tea.Cmd) {
+	m.editing = loc
+	m.inputs[fieldName].SetValue(loc.Name)
+	m.inputs[fieldCategory].SetValue(loc.Category)
+	m.inputs[fieldCityQuery].SetValue(loc.CityQuery)
+	m.err = nil
+	m.screen = editScreen
+	return m, m.focusField(fieldName)
+}
+
+func (m *model) focusField(i int) tea.Cmd {
+	m.focus = i
+	for j := range m.inputs {
+		m.inputs[j].Blur()
+	}
+	return m.inputs[i].Focus()
+}
+
+func (m model) updateEdit(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			m.err = nil
+			m.status = "Edit cancelled"
+			m.screen = listScreen
+			return m, nil
+		case "tab", "down":
+			return m, m.focusField((m.focus + 1) % len(m.inputs))
+		case "shift+tab", "up":
+			return m, m.focusField((m.focus + len(m.inputs) - 1) % len(m.inputs))
+		case "enter":
+			if m.focus < len(m.inputs)-1 {
+				return m, m.focusField(m.focus + 1)
+			}
+			return m.save()
+		case "ctrl+s":
+			return m.save()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	return m, cmd
+}
+
+// save writes the edited metadata. The location is re-read first so media
+// that finished generating meanwhile isn't overwritten.
+func (m model) save() (tea.Model, tea.Cmd) {
+	name := strings.TrimSpace(m.inputs[fieldName].Value())
+	category := strings.TrimSpace(m.inputs[fieldCategory].Value())
+	cityQuery := strings.TrimSpace(m.inputs[fieldCityQuery].Value())
+	if name == "" || cityQuery == "" {
+		m.err = fmt.Errorf("name and city query cannot be empty")
+		return m, nil
+	}
+
+	id, ns := m.editing.ID, m.editing.Namespace()
+	return m, func() tea.Msg {
+		loc, err := m.db.GetLocation(m.ctx, ns, id)
+		if err != nil {
+			return savedMsg{loc: database.Location{ID: id}, err: err}
+		}
+		loc.Name = name
+		loc.Category = category
+		loc.CityQuery = cityQuery
+		if err := m.db.UpsertLocation(m.ctx, *loc); err != nil {
+			return savedMsg{loc: *loc, err: err}
+		}
+		log.Printf("Admin console: updated %s", id)
+		return savedMsg{loc: *loc}
+	}
+}
+
+func (m model) viewEdit() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Edit "+m.editing.ID) + "\n\n")
+	for i, input := range m.inputs {
+		b.WriteString(labelStyle.Render(fieldLabels[i]) + input.View() + "\n")
+	}
+	b.WriteString("\n")
+	if m.err != nil {
+		b.WriteString(errorStyle.Render("Error: "+m.err.Error()) + "\n")
+	}
+	b.WriteString(helpStyle.Render("tab/↓ next • shift+tab/↑ previous • enter/ctrl+s save • esc cancel"))
+	return b.String()
+}
+
+// -- Generate --
+
+func (m model) updateConfirm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "y":
+			return m.startGeneration()
+		case "n", "esc", "q":
+			m.screen = listScreen
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewConfirm() string {
+	return titleStyle.Render("Generate "+m.target.ID) + "\n\n" +
+		fmt.Sprintf("Generate a new image and video for %q (%s)?\n", m.target.Name, m.target.CityQuery) +
+		"This calls Gemini and Veo and replaces the current media.\n\n" +
+		helpStyle.Render("y generate • n cancel")
+}
+
+// startGeneration runs the generator in the background. Its progress
+// messages and result are delivered through m.events, one per waitForEvent.
+func (m model) startGeneration() (tea.Model, tea.Cmd) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	events := make(chan tea.Msg, 1)
+	target := m.target
+	gen := m.gen
+
+	go func() {
+		log.Printf("Admin console: generating %s", target.ID)
+		loc, err := gen.GenerateLocation(ctx, target, func(msg string) {
+			select {
+			case events <- progressMsg(msg):
+			case <-ctx.Done():
+			}
+		})
+		events <- generatedMsg{loc: loc, err: err}
+	}()
+
+	m.screen = generateScreen
+	m.cancel, m.events = cancel, events
+	m.progress = nil
+	m.generated = nil
+	m.err = nil
+	m.started, m.finished = time.Now(), time.Time{}
+	return m, tea.Batch(m.spinner.Tick, waitForEvent(events))
+}
+
+func waitForEvent(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+func (m model) updateGenerate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		running := m.cancel != nil
+		switch {
+		case running && msg.String() == "esc":
+			m.cancel()
+			m.progress = append(m.progress, "Cancelling...")
+		case !running:
+			m.screen = listScreen
+			if m.err == nil {
+				m.status = "Generated " + m.target.ID
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewGenerate() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Generating "+m.target.ID) + "\n\n")
+	for _, line := range m.progress {
+		b.WriteString("  " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	switch {
+	case m.cancel != nil:
+		elapsed := time.Since(m.started).Round(time.Second)
+		b.WriteString(fmt.Sprintf("%s Working... %s\n\n", m.spinner.View(), elapsed))
+		b.WriteString(helpStyle.Render("esc cancel • ctrl+c quit"))
+	case m.err != nil:
+		b.WriteString(errorStyle.Render("Failed: "+m.err.Error()) + "\n\n")
+		b.WriteString(helpStyle.Render("any key to go back"))
+	default:
+		elapsed := m.finished.Sub(m.started).Round(time.Second)
+		fmt.Fprintf(&b, "Done in %s.\n", elapsed)
+		if m.generated != nil {
+			fmt.Fprintf(&b, "  Image: %s\n  Video: %s\n", m.generated.ImageURL, m.generated.VideoURL)
+		}
+		b.WriteString("\n" + helpStyle.Render("any key to go back"))
+	}
+	return b.String()
+}
+
+func (m model) View() string {
+	switch m.screen {
+	case editScreen:
+		return m.viewEdit()
+	case confirmScreen:
+		return m.viewConfirm()
+	case generateScreen:
+		return m.viewGenerate()
+	}
+	return m.viewList()
+}
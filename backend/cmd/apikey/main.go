@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"banana-weather/pkg/auth"
+	"banana-weather/pkg/database"
+	"github.com/joho/godotenv"
+)
+
+// apikey issues and manages the API keys accepted by the server.
+//
+//	go run cmd/apikey/main.go -create -name "Partner App"
+//	go run cmd/apikey/main.go -create -name "Gallery Team" -admin
+//	go run cmd/apikey/main.go -list
+//	go run cmd/apikey/main.go -disable bw_1a2b3c4d
+func main() {
+	// Load .env
+	_ = godotenv.Load("../../.env")
+	_ = godotenv.Load("../.env")
+	_ = godotenv.Load(".env")
+
+	create := flag.Bool("create", false, "Issue a new key (requires -name)")
+	name := flag.String("name", "", "Who the key is for")
+	admin := flag.Bool("admin", false, "With -create: allow the key to use /api/admin")
+	list := flag.Bool("list", false, "List keys and their usage")
+	disable := flag.String("disable", "", "Disable the key with this prefix")
+	enable := flag.String("enable", "", "Re-enable the key with this prefix")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	dbService, err := database.NewStore(ctx)
+	if err != nil {
+		log.Fatalf("Failed to init DB: %v", err)
+	}
+	defer dbService.Close()
+
+	switch {
+	case *create:
+		if *name == "" {
+			log.Fatal("-create requires -name")
+		}
+		key, record := auth.NewKey(*name)
+		record.Admin = *admin
+		if err := dbService.UpsertAPIKey(ctx, record); err != nil {
+			log.Fatalf("Failed to store key: %v", err)
+		}
+		fmt.Printf("Created key for %s. It is shown only once:\n\n  %s\n\n", *name, key)
+	case *list:
+		listKeys(ctx, dbService)
+	case *disable != "":
+		setDisabled(ctx, dbService, *disable, true)
+	case *enable != "":
+		setDisabled(ctx, dbService, *enable, false)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func listKeys(ctx context.Context, db database.APIKeyStore) {
+	keys, err := db.ListAPIKeys(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list keys: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tNAME\tSTATUS\tIMAGES\tVIDEOS\tCACHE HITS\tLAST USED")
+	for _, k := range keys {
+		status := "active"
+		if k.Disabled {
+			status = "disabled"
+		} else if k.Admin {
+			status = "admin"
+		}
+		lastUsed := "never"
+		if !k.LastUsedAt.IsZero() {
+			lastUsed = k.LastUsedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", k.Prefix, k.Name, status,
+			k.Usage[database.UsageImages], k.Usage[database.UsageVideos], k.Usage[database.UsageCacheHits], lastUsed)
+	}
+	w.Flush()
+}
+
+func setDisabled(ctx context.Context, db database.APIKeyStore, prefix string, disabled bool) {
+	keys, err := db.ListAPIKeys(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list keys: %v", err)
+	}
+
+	var matches []database.APIKey
+	for _, k := range keys {
+		if strings.HasPrefix(k.Prefix, prefix) || strings.HasPrefix(prefix, k.Prefix) {
+			matches = append(matches, k)
+		}
+	}
+	if len(matches) != 1 {
+		log.Fatalf("Prefix %q matches %d keys; use the full prefix shown by -list", prefix, len(matches))
+	}
+
+	key := matches[0]
+	key.Disabled = disabled
+	if err := db.UpsertAPIKey(ctx, key); err != nil {
+		log.Fatalf("Failed to update key: %v", err)
+	}
+	log.Printf("Key %s (%s) disabled: %v", key.Prefix, key.Name, disabled)
+}
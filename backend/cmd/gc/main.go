@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/gc"
+	"banana-weather/pkg/storage"
+	"github.com/joho/godotenv"
+)
+
+// gc finds generated media that no location references any more and, with
+// -archive or -delete, removes it. Without either it only reports.
+//
+//	go run cmd/gc/main.go
+//	go run cmd/gc/main.go -archive
+//	go run cmd/gc/main.go -delete -grace 168h
+func main() {
+	// Load .env
+	_ = godotenv.Load("../../.env")
+	_ = godotenv.Load("../.env")
+	_ = godotenv.Load(".env")
+
+	archive := flag.Bool("archive", false, "Move unreferenced objects under "+storage.ArchivePrefix)
+	del := flag.Bool("delete", false, "Delete unreferenced objects")
+	grace := flag.Duration("grace", gc.DefaultGrace, "Leave unreferenced objects younger than this alone")
+	flag.Parse()
+
+	action := gc.DryRun
+	switch {
+	case *archive && *del:
+		log.Fatal("Use only one of -archive and -delete")
+	case *archive:
+		action = gc.Archive
+	case *del:
+		action = gc.Delete
+	}
+
+	ctx := context.Background()
+
+	blobStore, err := storage.NewBlobStore(ctx)
+	if err != nil {
+		log.Fatalf("Failed to init Storage: %v", err)
+	}
+	objects, ok := blobStore.(storage.ObjectStore)
+	if !ok {
+		log.Fatalf("Storage backend %T cannot list objects", blobStore)
+	}
+	dbService, err := database.NewStore(ctx)
+	if err != nil {
+		log.Fatalf("Failed to init DB: %v", err)
+	}
+	defer dbService.Close()
+
+	report, err := gc.NewCollector(objects, dbService, *grace, action).Run(ctx)
+	if err != nil {
+		log.Fatalf("GC failed: %v", err)
+	}
+
+	if len(report.Orphans) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "OBJECT\tSIZE\tAGE")
+		for _, obj := range report.Orphans {
+			age := time.Since(obj.Created).Round(time.Hour)
+			fmt.Fprintf(w, "%s\t%d\t%s\n", obj.Name, obj.Size, age)
+		}
+		w.Flush()
+	}
+	fmt.Println(report)
+	if action == gc.DryRun && len(report.Orphans) > 0 {
+		fmt.Println("Dry run: nothing was removed. Pass -archive or -delete to remove these objects.")
+	}
+}
@@ -4,14 +4,12 @@ import (
 	"context"
 	"encoding/csv"
 	"flag"
-	"fmt"
 	"log"
 	"os"
-	"strings"
-	"time"
 
 	"banana-weather/pkg/database"
 	"banana-weather/pkg/genai"
+	"banana-weather/pkg/presets"
 	"banana-weather/pkg/storage"
 	"github.com/joho/godotenv"
 )
@@ -41,16 +39,24 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to init GenAI: %v", err)
 	}
-	storageService, err := storage.NewService(ctx)
+	storageService, err := storage.NewBlobStore(ctx)
 	if err != nil {
 		log.Fatalf("Failed to init Storage: %v", err)
 	}
-	dbService, err := database.NewClient(ctx)
+	genaiService.SetVideoStore(storageService)
+	dbService, err := database.NewStore(ctx)
 	if err != nil {
 		log.Fatalf("Failed to init DB: %v", err)
 	}
 	defer dbService.Close()
 
+	gen := &presets.Generator{
+		Images:  genaiService,
+		Videos:  genaiService,
+		Storage: storageService,
+		DB:      dbService,
+	}
+
 	if *csvPath != "" {
 		// Batch Mode
 		log.Printf("Running in Batch Mode from %s (Force: %v)", *csvPath, *force)
@@ -76,44 +82,26 @@ func main() {
 			pCat := row[3]
 			pCtx := ""
 			if len(row) > 4 { pCtx = row[4] }
+			preset := presets.Preset{ID: pID, Name: pName, Category: pCat, City: pCity, Context: pCtx}
 
 			// Check Existing
-			existing, err := dbService.GetLocation(ctx, pID)
+			existing, err := dbService.GetLocation(ctx, database.PresetNamespace, pID)
 			exists := err == nil && existing != nil
 
 			if exists && !*force {
 				log.Printf("Skipping generation for [%s], updating metadata only.", pID)
-				// Patch metadata
-				existing.Name = pName
-				existing.Category = pCat
-				existing.IsPreset = true
-				// Preserve URLs
-				if err := dbService.UpsertLocation(ctx, *existing); err != nil {
+				// Patch metadata, preserving URLs
+				if err := gen.PatchMetadata(ctx, preset); err != nil {
 					log.Printf("Failed to patch %s: %v", pID, err)
 				}
 				continue
 			}
 
 			log.Printf("Processing [%d/%d]: %s (%s)", i, len(records)-1, pName, pID)
-			imgURL, vidURL, err := processPreset(ctx, genaiService, storageService, pID, pCity, pCtx)
-			if err != nil {
+			if _, err := gen.Generate(ctx, preset, nil); err != nil {
 				log.Printf("Error processing %s: %v", pID, err)
 				continue
 			}
-			
-			// Save to DB
-			loc := database.Location{
-				ID:        pID,
-				Name:      pName,
-				Category:  pCat,
-				CityQuery: pCity,
-				ImageURL:  imgURL,
-				VideoURL:  vidURL,
-				IsPreset:  true,
-			}
-			if err := dbService.UpsertLocation(ctx, loc); err != nil {
-				log.Printf("Failed to save %s: %v", pID, err)
-			}
 		}
 
 	} else {
@@ -122,67 +110,21 @@ func main() {
 			log.Fatal("Missing required flags: -city, -name, -id (or -csv)")
 		}
 		
-		existing, err := dbService.GetLocation(ctx, *id)
+		preset := presets.Preset{ID: *id, Name: *name, Category: *category, City: *city, Context: *ctxPrompt}
+		existing, err := dbService.GetLocation(ctx, database.PresetNamespace, *id)
 		exists := err == nil && existing != nil
 
 		if exists && !*force {
 			log.Printf("Skipping generation for [%s], updating metadata only.", *id)
-			existing.Name = *name
-			existing.Category = *category
-			existing.IsPreset = true
-			if err := dbService.UpsertLocation(ctx, *existing); err != nil {
+			if err := gen.PatchMetadata(ctx, preset); err != nil {
 				log.Fatalf("Failed to patch %s: %v", *id, err)
 			}
 		} else {
-			imgURL, vidURL, err := processPreset(ctx, genaiService, storageService, *id, *city, *ctxPrompt)
-			if err != nil {
+			if _, err := gen.Generate(ctx, preset, nil); err != nil {
 				log.Fatalf("Error: %v", err)
 			}
-			loc := database.Location{
-				ID:        *id,
-				Name:      *name,
-				Category:  *category,
-				CityQuery: *city,
-				ImageURL:  imgURL,
-				VideoURL:  vidURL,
-				IsPreset:  true,
-			}
-			if err := dbService.UpsertLocation(ctx, loc); err != nil {
-				log.Fatalf("Failed to save: %v", err)
-			}
 		}
 	}
 	
 	log.Println("Done.")
 }
-
-func processPreset(ctx context.Context, gs *genai.Service, ss *storage.Service, id, city, promptCtx string) (string, string, error) {
-	// 1. Generate Image
-	log.Printf("Generating image for '%s'...", city)
-	imgBase64, err := gs.GenerateImage(ctx, city, promptCtx)
-	if err != nil {
-		return "", "", fmt.Errorf("image gen failed: %w", err)
-	}
-
-	// 2. Upload Image
-	imgFileName := fmt.Sprintf("preset_%s_image_%d.png", id, time.Now().Unix())
-	gsImageURI, publicImageURL, err := ss.UploadImage(ctx, imgBase64, imgFileName)
-	if err != nil {
-		return "", "", fmt.Errorf("image upload failed: %w", err)
-	}
-	log.Printf("Image uploaded: %s", publicImageURL)
-
-	// 3. Generate Video
-	log.Printf("Generating video (Veo)...")
-	videoPrompt := "The camera moves in parallax as the elements in the image move naturally, while the forecast data—the bold title remain fixed."
-	videoGsURI, err := gs.GenerateVideo(ctx, gsImageURI, videoPrompt)
-	if err != nil {
-		return "", "", fmt.Errorf("video gen failed: %w", err)
-	}
-	
-	bucketName := os.Getenv("GENMEDIA_BUCKET")
-	publicVideoURL := strings.Replace(videoGsURI, "gs://"+bucketName, "https://storage.googleapis.com/"+bucketName, 1)
-	log.Printf("Video generated: %s", publicVideoURL)
-
-	return publicImageURL, publicVideoURL, nil
-}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/gazetteer"
+	"banana-weather/pkg/maps"
+	"github.com/joho/godotenv"
+)
+
+// migrate_ids moves cached user locations to IDs made of a slug of their
+// name and a hash of their place ID (see database.UserLocationID). Locations
+// cached before place IDs and coordinates were recorded are geocoded by name
+// first. Without -apply it only reports.
+//
+// Run cmd/repair_presets first: user locations left in the preset namespace
+// by older versions are skipped here, since one of them may be a clobbered
+// preset that repair_presets restores in place.
+//
+//	go run cmd/migrate_ids/main.go
+//	go run cmd/migrate_ids/main.go -apply
+func main() {
+	// Load .env
+	_ = godotenv.Load("../../.env")
+	_ = godotenv.Load("../.env")
+	_ = godotenv.Load(".env")
+
+	apply := flag.Bool("apply", false, "Move the locations (default: report only)")
+	dev := flag.Bool("dev", false, "Geocode with the offline gazetteer instead of Google Maps")
+	flag.Parse()
+
+	ctx := context.Background()
+
+	var geocoder maps.Geocoder
+	var err error
+	if *dev {
+		geocoder, err = gazetteer.NewServiceFromEnv()
+	} else {
+		geocoder, err = maps.NewService()
+	}
+	if err != nil {
+		log.Fatalf("Failed to init geocoder: %v", err)
+	}
+	dbService, err := database.NewStore(ctx)
+	if err != nil {
+		log.Fatalf("Failed to init DB: %v", err)
+	}
+	defer dbService.Close()
+
+	moved, merged, legacy, err := migrateIDs(ctx, dbService, geocoder, *apply)
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	fmt.Printf("%d location(s) moved or located, %d duplicate(s) of the same place removed\n", moved, merged)
+	if legacy > 0 {
+		fmt.Printf("%d user location(s) in the preset namespace were skipped. Run repair_presets, then migrate_ids again.\n", legacy)
+	}
+	if !*apply && moved+merged > 0 {
+		fmt.Println("Dry run: nothing was changed. Pass -apply to migrate.")
+	}
+}
+
+// migrateIDs moves every location of the user namespace whose ID isn't the
+// one database.ResolveUserLocationID gives it. Of several locations of the
+// same place, the most recently updated one is kept. Non-preset documents of
+// the preset namespace are left to repair_presets and counted as legacy.
+func migrateIDs(ctx context.Context, db database.Store, geocoder maps.Geocoder, apply bool) (moved, merged, legacy int, err error) {
+	listed, err := db.ListLocations(ctx)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	// ResolveUserLocationID records collisions in stores that keep them; a
+	// dry run only logs them.
+	var resolver database.LocationStore = db
+	if !apply {
+		resolver = readOnly{db}
+	}
+
+	// ListLocations doesn't say which namespace a document came from, and an
+	// ID may be in both: read each one from the user namespace.
+	seen := make(map[string]int)
+	for _, l := range listed {
+		if !l.IsPreset {
+			seen[l.ID]++
+		}
+	}
+	for _, l := range listed {
+		if l.IsPreset || seen[l.ID] == 0 {
+			continue
+		}
+		count := seen[l.ID]
+		seen[l.ID] = 0
+
+		current, err := db.GetLocation(ctx, database.UserNamespace, l.ID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return moved, merged, legacy, err
+		}
+		if current == nil || count > 1 {
+			log.Printf("Skipping %s in the preset namespace: run repair_presets first", l.ID)
+			legacy++
+		}
+		if current == nil {
+			continue
+		}
+		loc := *current
+
+		if loc.VideoOpName != "" {
+			log.Printf("Skipping %s: a video is still being generated. Run again later.", loc.ID)
+			continue
+		}
+
+		located := false
+		if loc.PlaceID == "" || !loc.HasCoordinates() {
+			places, err := geocoder.FindCities(ctx, loc.Name)
+			switch {
+			case err != nil:
+				log.Printf("Failed to geocode %s (%q): %v", loc.ID, loc.Name, err)
+			case loc.PlaceID == "" || loc.PlaceID == places[0].ID:
+				loc.PlaceID, loc.Lat, loc.Lng = places[0].ID, places[0].Lat, places[0].Lng
+				located = true
+			}
+		}
+
+		oldID := loc.ID
+		newID, err := database.ResolveUserLocationID(ctx, resolver, loc.Name, loc.PlaceID)
+		if err != nil {
+			return moved, merged, legacy, fmt.Errorf("failed to resolve ID of %s: %w", oldID, err)
+		}
+		if newID == oldID {
+			if located {
+				log.Printf("Recording place and coordinates of %s", oldID)
+				moved++
+				if apply {
+					if err := db.MoveLocation(ctx, database.UserNamespace, oldID, loc); err != nil {
+						return moved, merged, legacy, fmt.Errorf("failed to update %s: %w", oldID, err)
+					}
+				}
+			}
+			continue
+		}
+
+		// An earlier location of the same place may already have moved there.
+		existing, err := db.GetLocation(ctx, database.UserNamespace, newID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return moved, merged, legacy, err
+		}
+		if existing != nil && existing.LastUpdated.After(loc.LastUpdated) {
+			log.Printf("Removing %s: %s is a newer location of the same place", oldID, newID)
+			merged++
+			if apply {
+				if err := db.DeleteLocation(ctx, database.UserNamespace, oldID); err != nil {
+					return moved, merged, legacy, fmt.Errorf("failed to delete %s: %w", oldID, err)
+				}
+			}
+			continue
+		}
+
+		log.Printf("Moving %s -> %s", oldID, newID)
+		moved++
+		if !apply {
+			continue
+		}
+		loc.ID = newID
+		if err := db.MoveLocation(ctx, database.UserNamespace, oldID, loc); err != nil {
+			return moved, merged, legacy, fmt.Errorf("failed to move %s: %w", oldID, err)
+		}
+	}
+	return moved, merged, legacy, nil
+}
+
+// readOnly hides every method of a Store but those of LocationStore, so
+// that it is not a CollisionStore.
+type readOnly struct {
+	database.LocationStore
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 
 	"banana-weather/pkg/database"
@@ -26,26 +27,35 @@ func main() {
 	ctx := context.Background()
 
 	// Init Services
-	storageService, err := storage.NewService(ctx)
+	storageService, err := storage.NewBlobStore(ctx)
 	if err != nil {
 		log.Fatalf("Failed to init Storage: %v", err)
 	}
 	
-	dbService, err := database.NewClient(ctx)
+	dbService, err := database.NewStore(ctx)
 	if err != nil {
 		log.Fatalf("Failed to init DB: %v", err)
 	}
 	defer dbService.Close()
 
-	log.Println("Reading presets.json from GCS...")
-	data, err := storageService.ReadObject(ctx, "presets.json")
+	if err := migratePresets(ctx, storageService, dbService); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	log.Println("Migration Complete.")
+}
+
+// migratePresets copies the legacy presets.json registry into the location store.
+func migratePresets(ctx context.Context, blobs storage.BlobStore, db database.LocationStore) error {
+	log.Println("Reading presets.json from storage...")
+	data, err := blobs.ReadObject(ctx, "presets.json")
 	if err != nil {
-		log.Fatalf("Failed to read presets.json: %v", err)
+		return fmt.Errorf("failed to read presets.json: %w", err)
 	}
 
 	var legacyList []LegacyPreset
 	if err := json.Unmarshal(data, &legacyList); err != nil {
-		log.Fatalf("Failed to parse JSON: %v", err)
+		return fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
 	log.Printf("Migrating %d presets to Firestore...", len(legacyList))
@@ -66,12 +76,12 @@ func main() {
 			loc.Category = "General"
 		}
 
-		if err := dbService.UpsertLocation(ctx, loc); err != nil {
+		if err := db.UpsertLocation(ctx, loc); err != nil {
 			log.Printf("Error migrating %s: %v", p.ID, err)
 		} else {
 			log.Printf("Migrated: %s", p.ID)
 		}
 	}
 
-	log.Println("Migration Complete.")
+	return nil
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/presets"
+	"github.com/joho/godotenv"
+)
+
+// repair_presets cleans up the preset namespace from before user searches
+// were stored apart. A user search whose ID matched a preset used to replace
+// the preset with a non-preset document; those are restored from the preset
+// CSV. Other non-preset documents are stale cache entries and are deleted.
+// Without -apply it only reports.
+//
+//	go run cmd/repair_presets/main.go
+//	go run cmd/repair_presets/main.go -csv presets_expanded.csv -apply
+func main() {
+	// Load .env
+	_ = godotenv.Load("../../.env")
+	_ = godotenv.Load("../.env")
+	_ = godotenv.Load(".env")
+
+	csvPath := flag.String("csv", "presets_expanded.csv", "Preset CSV (format: id,name,city,category,context)")
+	apply := flag.Bool("apply", false, "Write the repairs (default: report only)")
+	flag.Parse()
+
+	known, err := readPresets(*csvPath)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	ctx := context.Background()
+	dbService, err := database.NewStore(ctx)
+	if err != nil {
+		log.Fatalf("Failed to init DB: %v", err)
+	}
+	defer dbService.Close()
+
+	restored, deleted, err := repair(ctx, dbService, known, *apply)
+	if err != nil {
+		log.Fatalf("Repair failed: %v", err)
+	}
+
+	fmt.Printf("%d preset(s) restored, %d misplaced user location(s) deleted\n", restored, deleted)
+	if !*apply && restored+deleted > 0 {
+		fmt.Println("Dry run: nothing was changed. Pass -apply to repair.")
+	}
+	if restored > 0 {
+		fmt.Println("Restored presets keep the media of the search that replaced them; regenerate them with generate_preset -force or the admin API.")
+	}
+}
+
+// readPresets reads the preset CSV in the format of cmd/generate_preset.
+func readPresets(path string) (map[string]presets.Preset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV: %w", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	known := make(map[string]presets.Preset)
+	for i, row := range records {
+		if i == 0 || len(row) < 4 {
+			continue // Header or incomplete row
+		}
+		p := presets.Preset{ID: row[0], Name: row[1], City: row[2], Category: row[3]}
+		if len(row) > 4 {
+			p.Context = row[4]
+		}
+		known[p.ID] = p
+	}
+	return known, nil
+}
+
+// repair restores clobbered presets and deletes the remaining non-preset
+// documents of the preset namespace. Only documents in the preset namespace
+// are touched; user searches with the same ID in their own namespace are not.
+func repair(ctx context.Context, db database.LocationStore, known map[string]presets.Preset, apply bool) (restored, deleted int, err error) {
+	locations, err := db.ListLocations(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, listed := range locations {
+		if listed.IsPreset || seen[listed.ID] {
+			continue
+		}
+		seen[listed.ID] = true
+
+		// ListLocations doesn't say which namespace a document came from.
+		loc, err := db.GetLocation(ctx, database.PresetNamespace, listed.ID)
+		if err != nil || loc.IsPreset {
+			continue
+		}
+
+		if p, ok := known[loc.ID]; ok {
+			log.Printf("Restoring preset %s (overwritten by a search for %q)", loc.ID, loc.Name)
+			restored++
+			if !apply {
+				continue
+			}
+			loc.Name, loc.Category, loc.CityQuery, loc.PromptContext = p.Name, p.Category, p.City, p.Context
+			loc.IsPreset = true
+			loc.Forecast, loc.Fingerprint = nil, nil
+			loc.VideoOpName, loc.VideoOpStartedAt = "", nil
+			if err := db.UpsertLocation(ctx, *loc); err != nil {
+				return restored, deleted, fmt.Errorf("failed to restore %s: %w", loc.ID, err)
+			}
+			continue
+		}
+
+		log.Printf("Deleting user location %s from the preset namespace", loc.ID)
+		deleted++
+		if !apply {
+			continue
+		}
+		if err := db.DeleteLocation(ctx, database.PresetNamespace, loc.ID); err != nil {
+			return restored, deleted, fmt.Errorf("failed to delete %s: %w", loc.ID, err)
+		}
+	}
+	return restored, deleted, nil
+}
@@ -2,59 +2,57 @@ package main
 
 import (
 	"context"
+	"expvar"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"banana-weather/api"
+	"banana-weather/pkg/auth"
 	"banana-weather/pkg/database"
+	"banana-weather/pkg/gazetteer"
+	"banana-weather/pkg/gc"
 	"banana-weather/pkg/genai"
+	"banana-weather/pkg/geocache"
+	"banana-weather/pkg/jobs"
 	"banana-weather/pkg/maps"
+	"banana-weather/pkg/ratelimit"
 	"banana-weather/pkg/storage"
+	"banana-weather/pkg/weather"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
 func main() {
+	devMode := flag.Bool("dev", false, "Run fully offline with fake providers (same as BANANA_MODE=fake)")
+	flag.Parse()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	// Initialize Services
-	mapsService, err := maps.NewService()
-	if err != nil {
-		log.Fatalf("FATAL: Maps service failed to initialize. Check GOOGLE_MAPS_API_KEY. Error: %v", err)
+	if os.Getenv("BANANA_MODE") == "fake" {
+		*devMode = true
 	}
 
-	// GenAI Service
-	genaiService, err := genai.NewService(context.Background())
-	if err != nil {
-		log.Fatalf("FATAL: GenAI service failed to initialize. Check PROJECT_ID/GOOGLE_CLOUD_PROJECT. Error: %v", err)
+	var handler *api.Handler
+	var localMedia *storage.LocalService
+	if *devMode {
+		handler, localMedia = newDevHandler(port)
+	} else {
+		handler, localMedia = newCloudHandler()
 	}
+	defer handler.DB.Close()
 
-	// Storage Service
-	storageService, err := storage.NewService(context.Background())
-	if err != nil {
-		log.Printf("Warning: Storage service failed to initialize (Check GENMEDIA_BUCKET): %v", err)
-	}
-
-	// Database Service
-	dbService, err := database.NewClient(context.Background())
-	if err != nil {
-		log.Fatalf("FATAL: Database service failed to initialize. Check FIRESTORE_DATABASE. Error: %v", err)
-	}
-	defer dbService.Close()
-
-	handler := &api.Handler{
-		Maps:    mapsService,
-		GenAI:   genaiService,
-		Storage: storageService,
-		DB:      dbService,
-	}
+	// Finish videos orphaned by a previous restart or deploy.
+	go handler.ResumePendingVideos(context.Background())
 
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
@@ -62,10 +60,37 @@ func main() {
 
 	// API Routes
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/weather", handler.HandleGetWeather)
+		r.Use(cors)
+		if handler.Auth != nil {
+			r.Use(handler.Auth.Middleware)
+		}
+		r.With(limit(handler.Limiter)).Get("/weather", handler.HandleGetWeather)
 		r.Get("/presets", handler.HandleGetPresets)
+		r.With(limit(handler.Limiter)).Get("/geocode", handler.HandleGeocode)
+		r.With(limit(handler.Limiter)).Get("/autocomplete", handler.HandleAutocomplete)
+		r.Get("/jobs/{id}", handler.HandleGetJob)
+		r.Get("/usage", handler.HandleGetUsage)
+
+		// Runtime metrics, e.g. geocode_cache (admin API keys only)
+		r.With(auth.RequireAdmin).Get("/admin/vars", expvar.Handler().ServeHTTP)
+
+		// Gallery curation (admin API keys only)
+		r.Route("/admin/presets", func(r chi.Router) {
+			r.Use(auth.RequireAdmin)
+			r.Get("/", handler.HandleAdminListPresets)
+			r.Post("/", handler.HandleAdminCreatePreset)
+			r.Get("/{id}", handler.HandleAdminGetPreset)
+			r.Patch("/{id}", handler.HandleAdminPatchPreset)
+			r.Delete("/{id}", handler.HandleAdminDeletePreset)
+			r.Post("/{id}/regenerate", handler.HandleAdminRegeneratePreset)
+		})
 	})
 
+	// Locally stored media (STORAGE_BACKEND=local or dev mode)
+	if localMedia != nil {
+		r.Handle("/media/*", http.StripPrefix("/media", localMedia))
+	}
+
 	// Static Files (Frontend)
 	workDir, _ := os.Getwd()
 	filesDir := filepath.Join(workDir, "../frontend/build/web")
@@ -86,6 +111,271 @@ func main() {
 	}
 }
 
+// newCloudHandler wires the production Google Cloud services. The returned
+// LocalService is non-nil when STORAGE_BACKEND=local and must be served.
+func newCloudHandler() (*api.Handler, *storage.LocalService) {
+	// Initialize Services
+	// GenAI Service
+	genaiService, err := genai.NewService(context.Background())
+	if err != nil {
+		log.Fatalf("FATAL: GenAI service failed to initialize. Check PROJECT_ID/GOOGLE_CLOUD_PROJECT. Error: %v", err)
+	}
+
+	// Storage Service (optional: video generation is skipped without it)
+	blobStore, err := storage.NewBlobStore(context.Background())
+	if err != nil {
+		log.Printf("Warning: Storage service failed to initialize (Check STORAGE_BACKEND/GENMEDIA_BUCKET): %v", err)
+	} else {
+		genaiService.SetVideoStore(blobStore)
+	}
+	localMedia, _ := blobStore.(*storage.LocalService)
+
+	// Database Service
+	dbService, err := database.NewStore(context.Background())
+	if err != nil {
+		log.Fatalf("FATAL: Database service failed to initialize. Check DATABASE_BACKEND/FIRESTORE_DATABASE. Error: %v", err)
+	}
+
+	// Weather Provider (optional)
+	weatherProvider, err := weather.NewProvider()
+	if err != nil {
+		log.Fatalf("FATAL: Weather provider failed to initialize. Check WEATHER_PROVIDER. Error: %v", err)
+	}
+
+	// Jobs, coalesced across instances through database leases
+	jobManager := jobs.NewManager(context.Background(), dbService)
+	jobManager.SetLeaseStore(dbService)
+
+	// Video Worker Pool
+	videoPool, err := jobs.NewVideoPool(context.Background())
+	if err != nil {
+		log.Fatalf("FATAL: Video pool failed to initialize. Check VIDEO_WORKERS/VIDEO_QUEUE/VIDEO_TIMEOUT. Error: %v", err)
+	}
+
+	if blobStore != nil {
+		startGC(blobStore, dbService)
+	}
+
+	gazetteerService := newGazetteer()
+	geocoder, autocompleter := newGeocoder(dbService, gazetteerService)
+
+	return &api.Handler{
+		Maps:         geocoder,
+		Autocomplete: autocompleter,
+		Timezones:    gazetteerService,
+		Images:       genaiService,
+		Videos:       genaiService,
+		Storage:      blobStore,
+		DB:           dbService,
+		Weather:      weatherProvider,
+		Jobs:         jobManager,
+		VideoPool:    videoPool,
+		Limiter:      newLimiter(dbService),
+		Auth:         newAuthenticator(dbService),
+		Keys:         dbService,
+		Cache:        newCachePolicy(),
+	}, localMedia
+}
+
+// newDevHandler wires in-process fakes so the server runs without network
+// access or cloud credentials. Media is written to MEDIA_DIR (default: a temp
+// directory) and served from /media.
+func newDevHandler(port string) (*api.Handler, *storage.LocalService) {
+	log.Printf("Running in DEV mode with offline fake providers")
+
+	gazetteerService := newGazetteer()
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = filepath.Join(os.TempDir(), "banana-weather-media")
+	}
+	mediaBaseURL := os.Getenv("MEDIA_BASE_URL")
+	if mediaBaseURL == "" {
+		// Absolute so the Flutter debug server (different origin) can load it too.
+		mediaBaseURL = "http://localhost:" + port + "/media"
+	}
+	localMedia, err := storage.NewLocalService(mediaDir, mediaBaseURL)
+	if err != nil {
+		log.Fatalf("FATAL: Local storage failed to initialize. Check MEDIA_DIR. Error: %v", err)
+	}
+
+	placeholder := genai.NewPlaceholderService(localMedia)
+	store := database.NewMemoryStore()
+	jobManager := jobs.NewManager(context.Background(), store)
+
+	videoPool, err := jobs.NewVideoPool(context.Background())
+	if err != nil {
+		log.Fatalf("FATAL: Video pool failed to initialize. Check VIDEO_WORKERS/VIDEO_QUEUE/VIDEO_TIMEOUT. Error: %v", err)
+	}
+
+	startGC(localMedia, store)
+
+	return &api.Handler{
+		Maps:         newGeocodeCache(gazetteerService, store),
+		Autocomplete: gazetteerService,
+		Timezones:    gazetteerService,
+		Images:       placeholder,
+		Videos:       placeholder,
+		Storage:      localMedia,
+		DB:           store,
+		Weather:      weather.NewStub(gazetteerService),
+		Jobs:         jobManager,
+		VideoPool:    videoPool,
+		Limiter:      newLimiter(store),
+		Auth:         newAuthenticator(store),
+		Keys:         store,
+		Cache:        newCachePolicy(),
+	}, localMedia
+}
+
+// newLimiter creates the rate limiter. Counters are shared through db
+// (Firestore in production) unless RATE_LIMIT_STORE=memory, which keeps them
+// per instance.
+func newLimiter(db database.CounterStore) *ratelimit.Limiter {
+	var counters database.CounterStore
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "database":
+		counters = db
+	case "memory":
+		counters = database.NewMemoryStore()
+	default:
+		log.Fatalf("FATAL: Unknown RATE_LIMIT_STORE %q (want database or memory)", store)
+	}
+
+	limiter, err := ratelimit.NewLimiterFromEnv(counters)
+	if err != nil {
+		log.Fatalf("FATAL: Rate limiter failed to initialize. Check RATE_LIMIT_*. Error: %v", err)
+	}
+	return limiter
+}
+
+// newGeocoder returns the geocoder selected by GEOCODER and an autocompleter
+// for it:
+//   - "maps" (default): the Google Maps API, behind a geocode cache. With
+//     GEOCODER_FALLBACK=gazetteer the gazetteer answers when it fails.
+//   - "gazetteer": the offline gazetteer, with no need for
+//     GOOGLE_MAPS_API_KEY. It is fast enough not to need a cache.
+func newGeocoder(db database.GeocodeStore, gazetteerService *gazetteer.Service) (maps.Geocoder, maps.Autocompleter) {
+	switch v := os.Getenv("GEOCODER"); v {
+	case "", "maps":
+	case "gazetteer":
+		return gazetteerService, gazetteerService
+	default:
+		log.Fatalf("FATAL: Unknown GEOCODER %q (want maps or gazetteer)", v)
+	}
+
+	mapsService, err := maps.NewService()
+	if err != nil {
+		log.Fatalf("FATAL: Maps service failed to initialize. Check GOOGLE_MAPS_API_KEY. Error: %v", err)
+	}
+	var geocoder maps.Geocoder = newGeocodeCache(mapsService, db)
+
+	switch v := os.Getenv("GEOCODER_FALLBACK"); v {
+	case "", "none":
+	case "gazetteer":
+		geocoder = gazetteer.NewFallback(geocoder, gazetteerService)
+	default:
+		log.Fatalf("FATAL: Unknown GEOCODER_FALLBACK %q (want none or gazetteer)", v)
+	}
+	return geocoder, mapsService
+}
+
+// newGazetteer loads the GeoNames dump at GAZETTEER_FILE, or else the
+// embedded list of cities. Besides geocoding (see newGeocoder) it estimates
+// timezones.
+func newGazetteer() *gazetteer.Service {
+	gazetteerService, err := gazetteer.NewServiceFromEnv()
+	if err != nil {
+		log.Fatalf("FATAL: Gazetteer failed to load. Check GAZETTEER_FILE. Error: %v", err)
+	}
+	return gazetteerService
+}
+
+// newGeocodeCache puts a cache in front of the Maps API, shared through db
+// unless GEOCODE_CACHE_STORE=memory.
+func newGeocodeCache(geocoder maps.Geocoder, db database.GeocodeStore) *geocache.Cache {
+	cache, err := geocache.NewFromEnv(geocoder, db)
+	if err != nil {
+		log.Fatalf("FATAL: Geocode cache failed to initialize. Check GEOCODE_CACHE_*. Error: %v", err)
+	}
+	return cache
+}
+
+// newCachePolicy reads the cache settings (CACHE_TTL_*, CACHE_MAX_STALE,
+// CACHE_CHANGE_THRESHOLD, CACHE_MATCH_RADIUS_KM).
+func newCachePolicy() database.CachePolicy {
+	cache, err := database.NewCachePolicyFromEnv()
+	if err != nil {
+		log.Fatalf("FATAL: Cache policy failed to initialize. Check CACHE_TTL_*. Error: %v", err)
+	}
+	return cache
+}
+
+// newAuthenticator validates API keys stored in keys. Requests without a key
+// are allowed unless ALLOW_ANONYMOUS=false.
+func newAuthenticator(keys database.APIKeyStore) *auth.Authenticator {
+	allowAnonymous := true
+	if v := os.Getenv("ALLOW_ANONYMOUS"); v != "" {
+		var err error
+		allowAnonymous, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("FATAL: Invalid ALLOW_ANONYMOUS %q", v)
+		}
+	}
+	if !allowAnonymous {
+		log.Printf("Anonymous API access disabled; requests need an API key")
+	}
+	return auth.NewAuthenticator(keys, allowAnonymous)
+}
+
+// startGC collects unreferenced media every GC_INTERVAL (e.g. "24h"; off by
+// default), as configured for gc.NewCollectorFromEnv. cmd/gc does the same
+// on demand.
+func startGC(blobs storage.BlobStore, db database.Store) {
+	v := os.Getenv("GC_INTERVAL")
+	if v == "" {
+		return
+	}
+	interval, err := time.ParseDuration(v)
+	if err != nil || interval <= 0 {
+		log.Fatalf("FATAL: Invalid GC_INTERVAL %q", v)
+	}
+	objects, ok := blobs.(storage.ObjectStore)
+	if !ok {
+		log.Printf("Warning: GC_INTERVAL is set but %T cannot list objects; GC disabled", blobs)
+		return
+	}
+	collector, err := gc.NewCollectorFromEnv(objects, db)
+	if err != nil {
+		log.Fatalf("FATAL: GC failed to initialize. Check GC_GRACE/GC_ACTION. Error: %v", err)
+	}
+	go collector.Schedule(context.Background(), interval, db)
+}
+
+// limit returns the rate limiting middleware, or a no-op without a limiter.
+func limit(l *ratelimit.Limiter) func(http.Handler) http.Handler {
+	if l == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return l.Middleware
+}
+
+// cors answers CORS preflight requests, which browsers send without
+// credentials before any cross-origin request carrying an API key header.
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, Authorization, Last-Event-ID")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
 // FileServer conveniently sets up a http.FileServer handler to serve
 // static files from a http.FileSystem.
 func FileServer(r chi.Router, path string, root http.FileSystem) {
@@ -105,4 +395,4 @@ func FileServer(r chi.Router, path string, root http.FileSystem) {
 		fs := http.StripPrefix(pathPrefix, http.FileServer(root))
 		fs.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
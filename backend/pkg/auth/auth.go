@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"banana-weather/pkg/database"
+)
+
+// keyPrefix starts every issued key, so leaked keys are easy to grep for.
+const keyPrefix = "bw_"
+
+// NewKey generates a new API key. It returns the key, to hand to the client
+// once, and the record to store.
+func NewKey(name string) (string, database.APIKey) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	key := keyPrefix + hex.EncodeToString(b)
+	return key, database.APIKey{
+		ID:        HashKey(key),
+		Name:      name,
+		Prefix:    key[:len(keyPrefix)+8],
+		Usage:     map[string]int64{},
+		CreatedAt: time.Now(),
+	}
+}
+
+// HashKey returns the ID under which a key is stored.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticator validates API keys against the database.
+type Authenticator struct {
+	store database.APIKeyStore
+
+	// AllowAnonymous lets requests without a key through. Requests with an
+	// invalid key are always rejected.
+	AllowAnonymous bool
+}
+
+func NewAuthenticator(store database.APIKeyStore, allowAnonymous bool) *Authenticator {
+	return &Authenticator{store: store, AllowAnonymous: allowAnonymous}
+}
+
+type contextKey struct{}
+
+// Middleware authenticates the request and attaches its API key (nil for
+// anonymous requests) to the context. The key is read from the X-API-Key
+// header or an "Authorization: Bearer" header. It is never taken from the
+// query string, which ends up in request logs. OPTIONS requests (CORS
+// preflights, which browsers send without the key) are let through.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw := requestKey(r)
+		if raw == "" {
+			if !a.AllowAnonymous {
+				http.Error(w, "API key required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := a.store.GetAPIKey(r.Context(), HashKey(raw))
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to look up API key: %v", err)
+			http.Error(w, "Failed to validate API key", http.StatusInternalServerError)
+			return
+		}
+		if key.Disabled {
+			http.Error(w, "API key disabled", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, key)))
+	})
+}
+
+func requestKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return ""
+}
+
+// RequireAdmin rejects requests that were not authenticated with an admin
+// key. It must run after Middleware.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := FromContext(r.Context())
+		if key == nil {
+			http.Error(w, "API key required", http.StatusUnauthorized)
+			return
+		}
+		if !key.Admin {
+			http.Error(w, "Admin API key required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FromContext returns the API key attached by Middleware, or nil for
+// anonymous requests.
+func FromContext(ctx context.Context) *database.APIKey {
+	key, _ := ctx.Value(contextKey{}).(*database.APIKey)
+	return key
+}
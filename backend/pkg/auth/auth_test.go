@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"banana-weather/pkg/database"
+)
+
+func TestNewKey(t *testing.T) {
+	key, record := NewKey("partner")
+	if !strings.HasPrefix(key, keyPrefix) || len(key) != len(keyPrefix)+48 {
+		t.Errorf("key %q, want %s and 48 hex digits", key, keyPrefix)
+	}
+	if record.ID != HashKey(key) || record.ID == key || strings.Contains(record.ID, key[len(keyPrefix):]) {
+		t.Errorf("stored ID %q, want the key's hash", record.ID)
+	}
+	if !strings.HasPrefix(key, record.Prefix) || len(record.Prefix) != len(keyPrefix)+8 {
+		t.Errorf("prefix %q, want the start of %q", record.Prefix, key)
+	}
+	if record.Name != "partner" || record.Admin || record.Disabled {
+		t.Errorf("record %+v, want an enabled non-admin key named partner", record)
+	}
+
+	if other, _ := NewKey("partner"); other == key {
+		t.Error("NewKey returned the same key twice")
+	}
+	if HashKey(key) != HashKey(key) || HashKey(key) == HashKey(key+"x") {
+		t.Error("HashKey is not a stable hash")
+	}
+}
+
+// serve runs r through the middleware of an Authenticator with keys and
+// returns the status and the key the handler saw.
+func serve(t *testing.T, a *Authenticator, r *http.Request) (int, *database.APIKey) {
+	t.Helper()
+	var seen *database.APIKey
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code, seen
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	valid, record := NewKey("partner")
+	store.UpsertAPIKey(ctx, record)
+	disabled, record2 := NewKey("former partner")
+	record2.Disabled = true
+	store.UpsertAPIKey(ctx, record2)
+	unknown, _ := NewKey("nobody")
+
+	tests := []struct {
+		name      string
+		anonymous bool
+		prepare   func(r *http.Request)
+		want      int
+		wantKey   bool
+	}{
+		{"header", false, func(r *http.Request) { r.Header.Set("X-API-Key", valid) }, http.StatusOK, true},
+		{"bearer", false, func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+valid) }, http.StatusOK, true},
+		{"no key", false, func(r *http.Request) {}, http.StatusUnauthorized, false},
+		{"anonymous", true, func(r *http.Request) {}, http.StatusOK, false},
+		{"query string", false, func(r *http.Request) { r.URL.RawQuery = "api_key=" + valid + "&key=" + valid }, http.StatusUnauthorized, false},
+		{"unknown key", true, func(r *http.Request) { r.Header.Set("X-API-Key", unknown) }, http.StatusUnauthorized, false},
+		{"disabled key", true, func(r *http.Request) { r.Header.Set("X-API-Key", disabled) }, http.StatusForbidden, false},
+		{"other scheme", false, func(r *http.Request) { r.Header.Set("Authorization", "Basic "+valid) }, http.StatusUnauthorized, false},
+		{"preflight", false, func(r *http.Request) {
+			r.Method = http.MethodOptions
+			r.Header.Set("Access-Control-Request-Headers", "x-api-key")
+		}, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/weather", nil)
+		tt.prepare(r)
+		code, key := serve(t, NewAuthenticator(store, tt.anonymous), r)
+		if code != tt.want || (key != nil) != tt.wantKey {
+			t.Errorf("%s: status %d, key %v; want %d", tt.name, code, key, tt.want)
+		}
+		if tt.wantKey && key.ID != HashKey(valid) {
+			t.Errorf("%s: handler saw key %s, want %s", tt.name, key.ID, HashKey(valid))
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	admin, record := NewKey("ops")
+	record.Admin = true
+	store.UpsertAPIKey(ctx, record)
+	partner, record2 := NewKey("partner")
+	store.UpsertAPIKey(ctx, record2)
+
+	handler := NewAuthenticator(store, true).Middleware(RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	for key, want := range map[string]int{admin: http.StatusOK, partner: http.StatusForbidden, "": http.StatusUnauthorized} {
+		r := httptest.NewRequest("GET", "/api/admin/presets", nil)
+		r.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("key %.11q: status %d, want %d", key, w.Code, want)
+		}
+	}
+}
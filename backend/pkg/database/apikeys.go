@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Usage counters kept per API key (APIKey.Usage).
+const (
+	UsageImages    = "images"
+	UsageVideos    = "videos"
+	UsageCacheHits = "cache_hits"
+)
+
+// APIKey is an issued API key. The key itself is never stored; ID is its
+// SHA-256 hash (see pkg/auth).
+type APIKey struct {
+	ID         string           `firestore:"id" json:"id"`
+	Name       string           `firestore:"name" json:"name"`     // Who the key was issued to
+	Prefix     string           `firestore:"prefix" json:"prefix"` // First characters of the key, to recognize it
+	Disabled   bool             `firestore:"disabled" json:"disabled"`
+	Admin      bool             `firestore:"admin" json:"admin"` // May use /api/admin
+	Usage      map[string]int64 `firestore:"usage" json:"usage"`
+	CreatedAt  time.Time        `firestore:"created_at" json:"created_at"`
+	LastUsedAt time.Time        `firestore:"last_used_at" json:"last_used_at"`
+}
+
+// APIKeyStore persists API keys and their usage.
+type APIKeyStore interface {
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	UpsertAPIKey(ctx context.Context, key APIKey) error
+	// RecordUsage adds one to the key's usage counter named kind.
+	RecordUsage(ctx context.Context, id string, kind string) error
+}
+
+// GetAPIKey retrieves an API key by ID.
+func (c *Client) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	doc, err := c.fs.Collection("api_keys").Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("api key %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var key APIKey
+	if err := doc.DataTo(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns all API keys.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	iter := c.fs.Collection("api_keys").Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var key APIKey
+		if err := doc.DataTo(&key); err != nil {
+			log.Printf("Failed to parse api key doc %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// UpsertAPIKey creates or replaces an API key document.
+func (c *Client) UpsertAPIKey(ctx context.Context, key APIKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("api key ID is required")
+	}
+	_, err := c.fs.Collection("api_keys").Doc(key.ID).Set(ctx, key)
+	return err
+}
+
+// RecordUsage increments a usage counter without reading the document.
+func (c *Client) RecordUsage(ctx context.Context, id string, kind string) error {
+	_, err := c.fs.Collection("api_keys").Doc(id).Update(ctx, []firestore.Update{
+		{FieldPath: firestore.FieldPath{"usage", kind}, Value: firestore.Increment(1)},
+		{Path: "last_used_at", Value: time.Now()},
+	})
+	return err
+}
@@ -0,0 +1,569 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	jobsBucket       = []byte("jobs")
+	leasesBucket     = []byte("leases")
+	countersBucket   = []byte("counters")
+	apiKeysBucket    = []byte("api_keys")
+	collisionsBucket = []byte("location_collisions")
+	geocodesBucket   = []byte("geocodes")
+)
+
+// locationsBucket returns the bucket of a location namespace, named like the
+// Firestore collection.
+func locationsBucket(ns Namespace) []byte {
+	return []byte(ns)
+}
+
+// BoltClient is an embedded LocationStore backed by a single bbolt file.
+// It keeps data across restarts without needing Firestore or its emulator.
+type BoltClient struct {
+	db *bolt.DB
+
+	mu        sync.Mutex
+	jobsSwept time.Time
+}
+
+func NewBoltClient(path string) (*BoltClient, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{locationsBucket(PresetNamespace), locationsBucket(UserNamespace), jobsBucket, leasesBucket, countersBucket, apiKeysBucket, collisionsBucket, geocodesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize bolt buckets: %w", err)
+	}
+
+	log.Printf("Initialized embedded database at %s", path)
+	return &BoltClient{db: db}, nil
+}
+
+// Close closes the database file.
+func (c *BoltClient) Close() error {
+	return c.db.Close()
+}
+
+// GetPresets returns all locations where is_preset = true, ordered by ID.
+// Older databases also kept user searches in the preset bucket.
+func (c *BoltClient) GetPresets(ctx context.Context) ([]Location, error) {
+	presets := []Location{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(locationsBucket(PresetNamespace)).ForEach(func(id, data []byte) error {
+			var loc Location
+			if err := json.Unmarshal(data, &loc); err != nil {
+				log.Printf("Failed to parse preset %s: %v", id, err)
+				return nil
+			}
+			if loc.IsPreset {
+				presets = append(presets, loc)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return presets, nil
+}
+
+// ListLocations returns all locations, presets first, each namespace ordered
+// by ID.
+func (c *BoltClient) ListLocations(ctx context.Context) ([]Location, error) {
+	var locations []Location
+	err := c.db.View(func(tx *bolt.Tx) error {
+		for _, ns := range []Namespace{PresetNamespace, UserNamespace} {
+			err := tx.Bucket(locationsBucket(ns)).ForEach(func(id, data []byte) error {
+				var loc Location
+				if err := json.Unmarshal(data, &loc); err != nil {
+					return fmt.Errorf("failed to parse location %s/%s: %w", ns, id, err)
+				}
+				locations = append(locations, loc)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// ListRecentLocations returns the user locations updated last. It scans
+// every user location.
+func (c *BoltClient) ListRecentLocations(ctx context.Context, limit int) ([]Location, error) {
+	var locations []Location
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(locationsBucket(UserNamespace)).ForEach(func(id, data []byte) error {
+			var loc Location
+			if err := json.Unmarshal(data, &loc); err != nil {
+				log.Printf("Failed to parse location %s: %v", id, err)
+				return nil
+			}
+			locations = append(locations, loc)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newest(locations, limit), nil
+}
+
+// GetPendingVideos returns all locations with a VideoOpName, presets first.
+// It scans every location; this only runs once at startup.
+func (c *BoltClient) GetPendingVideos(ctx context.Context) ([]Location, error) {
+	var pending []Location
+	err := c.db.View(func(tx *bolt.Tx) error {
+		for _, ns := range []Namespace{PresetNamespace, UserNamespace} {
+			err := tx.Bucket(locationsBucket(ns)).ForEach(func(id, data []byte) error {
+				var loc Location
+				if err := json.Unmarshal(data, &loc); err != nil {
+					log.Printf("Failed to parse location %s/%s: %v", ns, id, err)
+					return nil
+				}
+				if loc.VideoOpName != "" {
+					pending = append(pending, loc)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// FindLocationByPlaceID returns the user location with PlaceID placeID. It
+// scans every user location.
+func (c *BoltClient) FindLocationByPlaceID(ctx context.Context, placeID string) (*Location, error) {
+	var found *Location
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(locationsBucket(UserNamespace)).ForEach(func(id, data []byte) error {
+			var loc Location
+			if found != nil || json.Unmarshal(data, &loc) != nil {
+				return nil
+			}
+			if loc.PlaceID == placeID {
+				found = &loc
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("location of place %s: %w", placeID, ErrNotFound)
+	}
+	return found, nil
+}
+
+// ListLocationsInCells returns the user locations in any of cells. It scans
+// every user location.
+func (c *BoltClient) ListLocationsInCells(ctx context.Context, cells []string) ([]Location, error) {
+	var locations []Location
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(locationsBucket(UserNamespace)).ForEach(func(id, data []byte) error {
+			var loc Location
+			if err := json.Unmarshal(data, &loc); err != nil {
+				log.Printf("Failed to parse location %s: %v", id, err)
+				return nil
+			}
+			if loc.Cell != "" && slices.Contains(cells, loc.Cell) {
+				locations = append(locations, loc)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// UpsertLocation creates or replaces a location in its namespace.
+func (c *BoltClient) UpsertLocation(ctx context.Context, loc Location) error {
+	if loc.ID == "" {
+		return fmt.Errorf("location ID is required")
+	}
+
+	loc.LastUpdated = time.Now()
+	loc.Cell = cellOf(loc)
+	data, err := json.Marshal(loc)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(locationsBucket(loc.Namespace())).Put([]byte(loc.ID), data)
+	})
+}
+
+// UpdateLocation applies fn to a location in one transaction.
+func (c *BoltClient) UpdateLocation(ctx context.Context, ns Namespace, id string, fn func(loc *Location) bool) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(locationsBucket(ns))
+		data := b.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("location %s: %w", id, ErrNotFound)
+		}
+		var loc Location
+		if err := json.Unmarshal(data, &loc); err != nil {
+			return err
+		}
+		if !fn(&loc) {
+			return nil
+		}
+		loc.Cell = cellOf(loc)
+		data, err := json.Marshal(loc)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+}
+
+// MoveLocation replaces location oldID of ns with loc in one transaction,
+// keeping loc.LastUpdated.
+func (c *BoltClient) MoveLocation(ctx context.Context, ns Namespace, oldID string, loc Location) error {
+	if loc.ID == "" {
+		return fmt.Errorf("location ID is required")
+	}
+	if loc.Namespace() != ns {
+		return fmt.Errorf("location %s does not belong in %s", loc.ID, ns)
+	}
+	loc.Cell = cellOf(loc)
+	data, err := json.Marshal(loc)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(locationsBucket(ns))
+		if err := b.Delete([]byte(oldID)); err != nil {
+			return err
+		}
+		return b.Put([]byte(loc.ID), data)
+	})
+}
+
+// DeleteLocation removes a location.
+func (c *BoltClient) DeleteLocation(ctx context.Context, ns Namespace, id string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(locationsBucket(ns)).Delete([]byte(id))
+	})
+}
+
+// GetLocation retrieves a location by namespace and ID.
+func (c *BoltClient) GetLocation(ctx context.Context, ns Namespace, id string) (*Location, error) {
+	var loc *Location
+	err := c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(locationsBucket(ns)).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("location %s: %w", id, ErrNotFound)
+		}
+		loc = &Location{}
+		return json.Unmarshal(data, loc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loc, nil
+}
+
+// GetJob retrieves a job by ID.
+func (c *BoltClient) GetJob(ctx context.Context, id string) (*Job, error) {
+	var job *Job
+	err := c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(jobsBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("job %s: %w", id, ErrNotFound)
+		}
+		job = &Job{}
+		return json.Unmarshal(data, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if job.Expired(time.Now()) {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+	return job, nil
+}
+
+// UpsertJob creates or replaces a job, deleting expired jobs now and then.
+func (c *BoltClient) UpsertJob(ctx context.Context, job Job) error {
+	if job.ID == "" {
+		return fmt.Errorf("job ID is required")
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	sweep := now.Sub(c.jobsSwept) > jobSweepInterval
+	if sweep {
+		c.jobsSwept = now
+	}
+	c.mu.Unlock()
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+		if err := b.Put([]byte(job.ID), data); err != nil {
+			return err
+		}
+		if !sweep {
+			return nil
+		}
+
+		var expired [][]byte
+		err := b.ForEach(func(id, data []byte) error {
+			var j Job
+			if err := json.Unmarshal(data, &j); err != nil {
+				return err
+			}
+			if j.Expired(now) {
+				expired = append(expired, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range expired {
+			if err := b.Delete(id); err != nil {
+				return err
+			}
+		}
+		if len(expired) > 0 {
+			log.Printf("Deleted %d expired jobs", len(expired))
+		}
+		return nil
+	})
+}
+
+// AcquireLease takes or renews the lease on key for holder.
+func (c *BoltClient) AcquireLease(ctx context.Context, key, holder string, ttl time.Duration) (string, error) {
+	current := holder
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(leasesBucket)
+		now := time.Now()
+		if data := b.Get([]byte(key)); data != nil {
+			var lease Lease
+			if err := json.Unmarshal(data, &lease); err != nil {
+				return err
+			}
+			if lease.heldByOther(holder, now) {
+				current = lease.Holder
+				return nil
+			}
+		}
+		data, err := json.Marshal(Lease{Holder: holder, ExpiresAt: now.Add(ttl)})
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+	if err != nil {
+		return "", err
+	}
+	return current, nil
+}
+
+// ReleaseLease removes the lease on key if holder still holds it.
+func (c *BoltClient) ReleaseLease(ctx context.Context, key, holder string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(leasesBucket)
+		data := b.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		var lease Lease
+		if err := json.Unmarshal(data, &lease); err != nil {
+			return err
+		}
+		if lease.Holder != holder {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// IncrementCounter adds one to the counter for key unless it has reached limit.
+func (c *BoltClient) IncrementCounter(ctx context.Context, key string, limit int, window time.Duration) (Counter, bool, error) {
+	var counter Counter
+	var ok bool
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(countersBucket)
+		var current Counter
+		if data := b.Get([]byte(key)); data != nil {
+			if err := json.Unmarshal(data, &current); err != nil {
+				return err
+			}
+		}
+		counter, ok = incrementCounter(current, limit, window, time.Now())
+		data, err := json.Marshal(counter)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+	if err != nil {
+		return Counter{}, false, err
+	}
+	return counter, ok, nil
+}
+
+// GetAPIKey retrieves an API key by ID.
+func (c *BoltClient) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	var key *APIKey
+	err := c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(apiKeysBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("api key %s: %w", id, ErrNotFound)
+		}
+		key = &APIKey{}
+		return json.Unmarshal(data, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns all API keys, ordered by ID.
+func (c *BoltClient) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeysBucket).ForEach(func(id, data []byte) error {
+			var key APIKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				log.Printf("Failed to parse api key %s: %v", id, err)
+				return nil
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// UpsertAPIKey creates or replaces an API key.
+func (c *BoltClient) UpsertAPIKey(ctx context.Context, key APIKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("api key ID is required")
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(apiKeysBucket).Put([]byte(key.ID), data)
+	})
+}
+
+// RecordUsage adds one to the key's usage counter named kind.
+func (c *BoltClient) RecordUsage(ctx context.Context, id string, kind string) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(apiKeysBucket)
+		data := b.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("api key %s: %w", id, ErrNotFound)
+		}
+		var key APIKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return err
+		}
+		if key.Usage == nil {
+			key.Usage = make(map[string]int64)
+		}
+		key.Usage[kind]++
+		key.LastUsedAt = time.Now()
+		data, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+}
+
+// RecordCollision stores col, replacing an earlier record of the same ID.
+func (c *BoltClient) RecordCollision(ctx context.Context, col Collision) error {
+	if col.ID == "" {
+		return fmt.Errorf("collision ID is required")
+	}
+	data, err := json.Marshal(col)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(collisionsBucket).Put([]byte(col.ID), data)
+	})
+}
+
+// GetGeocode retrieves a geocoding result by key.
+func (c *BoltClient) GetGeocode(ctx context.Context, key string) (*Geocode, error) {
+	var g *Geocode
+	err := c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(geocodesBucket).Get([]byte(key))
+		if data == nil {
+			return fmt.Errorf("geocode %s: %w", key, ErrNotFound)
+		}
+		g = &Geocode{}
+		return json.Unmarshal(data, g)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// PutGeocode creates or replaces a geocoding result. Expired results are
+// only replaced, never removed.
+func (c *BoltClient) PutGeocode(ctx context.Context, g Geocode) error {
+	if g.Key == "" {
+		return fmt.Errorf("geocode key is required")
+	}
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(geocodesBucket).Put([]byte(g.Key), data)
+	})
+}
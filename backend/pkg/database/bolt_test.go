@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newBoltClient(t *testing.T, path string) *BoltClient {
+	t.Helper()
+	c, err := NewBoltClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestBoltClient(t *testing.T) {
+	c := newBoltClient(t, filepath.Join(t.TempDir(), "test.db"))
+	defer c.Close()
+	testStore(t, c)
+}
+
+func TestBoltClientKeepsData(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	c := newBoltClient(t, path)
+	c.UpsertLocation(ctx, Location{ID: "paris", Name: "Paris", IsPreset: true, ImageURL: "paris.png"})
+	c.UpsertLocation(ctx, Location{ID: "lyon", Name: "Lyon", Lat: 45.76, Lng: 4.84})
+	c.UpsertJob(ctx, Job{ID: "job1", Stage: JobDone, ExpiresAt: time.Now().Add(time.Hour)})
+	c.UpsertAPIKey(ctx, APIKey{ID: "hash1", Name: "partner"})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newBoltClient(t, path)
+	defer c.Close()
+	if loc, err := c.GetLocation(ctx, PresetNamespace, "paris"); err != nil || loc.ImageURL != "paris.png" {
+		t.Errorf("preset after reopening = %+v, %v", loc, err)
+	}
+	if loc, err := c.GetLocation(ctx, UserNamespace, "lyon"); err != nil || loc.Cell != Cell(45.76, 4.84) {
+		t.Errorf("user location after reopening = %+v, %v", loc, err)
+	}
+	if job, err := c.GetJob(ctx, "job1"); err != nil || job.Stage != JobDone {
+		t.Errorf("job after reopening = %+v, %v", job, err)
+	}
+	if key, err := c.GetAPIKey(ctx, "hash1"); err != nil || key.Name != "partner" {
+		t.Errorf("API key after reopening = %+v, %v", key, err)
+	}
+}
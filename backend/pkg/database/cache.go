@@ -0,0 +1,153 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"banana-weather/pkg/weather"
+)
+
+// DefaultUserTTL is how long a cached user location is served by default.
+const DefaultUserTTL = 3 * time.Hour
+
+// DefaultMaxStale is how old cached media may be and still be shown while it
+// is regenerated, unless CACHE_MAX_STALE says otherwise.
+const DefaultMaxStale = 24 * time.Hour
+
+// DefaultMatchRadiusKm is how close a cached location must be to a place
+// without its own to be served for it, unless CACHE_MATCH_RADIUS_KM says
+// otherwise.
+const DefaultMatchRadiusKm = 10.0
+
+// DefaultChangeThreshold is how many °C the forecast high or low must move
+// before cached media is regenerated.
+const DefaultChangeThreshold = 3.0
+
+// CachePolicy decides when cached media must be regenerated. Generated images
+// print the date, so besides expiring after a TTL, media expires at midnight
+// in the location's timezone.
+//
+// When a fresh forecast is available, StaleAgainst also expires media early
+// once it differs materially from the forecast the media was generated from.
+//
+// The zero value expires user locations after DefaultUserTTL, never expires
+// presets, which are curated and regenerated explicitly, uses
+// DefaultChangeThreshold, never serves stale media and only serves a place
+// its own cached location.
+type CachePolicy struct {
+	UserTTL         time.Duration // 0: DefaultUserTTL
+	PresetTTL       time.Duration // 0: never expire
+	ChangeThreshold float64       // °C; 0: DefaultChangeThreshold
+
+	// MaxStale is how old stale media may be and still be served, flagged,
+	// while it is regenerated (stale-while-revalidate). 0 disables it.
+	MaxStale time.Duration
+
+	// MatchRadiusKm is how far (at most MaxMatchRadiusKm) the nearest cached
+	// location may be from a place that has none of its own and still be
+	// served for it, so that e.g. a neighborhood shares its city's
+	// forecast. 0 disables it.
+	MatchRadiusKm float64
+}
+
+// NewCachePolicyFromEnv reads CACHE_TTL_USER (default 3h), CACHE_TTL_PRESET
+// (default 0, never), CACHE_CHANGE_THRESHOLD (default 3, in °C),
+// CACHE_MAX_STALE (default 24h, 0 disables) and CACHE_MATCH_RADIUS_KM
+// (default 10, 0 disables).
+func NewCachePolicyFromEnv() (CachePolicy, error) {
+	p := CachePolicy{MaxStale: DefaultMaxStale, MatchRadiusKm: DefaultMatchRadiusKm}
+	for name, ttl := range map[string]*time.Duration{
+		"CACHE_TTL_USER":   &p.UserTTL,
+		"CACHE_TTL_PRESET": &p.PresetTTL,
+		"CACHE_MAX_STALE":  &p.MaxStale,
+	} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return CachePolicy{}, fmt.Errorf("invalid %s %q", name, v)
+		}
+		*ttl = d
+	}
+	if v := os.Getenv("CACHE_CHANGE_THRESHOLD"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 {
+			return CachePolicy{}, fmt.Errorf("invalid CACHE_CHANGE_THRESHOLD %q", v)
+		}
+		p.ChangeThreshold = t
+	}
+	if v := os.Getenv("CACHE_MATCH_RADIUS_KM"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > MaxMatchRadiusKm {
+			return CachePolicy{}, fmt.Errorf("invalid CACHE_MATCH_RADIUS_KM %q (0 to %d)", v, MaxMatchRadiusKm)
+		}
+		p.MatchRadiusKm = r
+	}
+	return p, nil
+}
+
+// TTL returns how long loc's media is valid at most; 0 means forever.
+func (p CachePolicy) TTL(loc *Location) time.Duration {
+	if loc.IsPreset {
+		return p.PresetTTL
+	}
+	if p.UserTTL == 0 {
+		return DefaultUserTTL
+	}
+	return p.UserTTL
+}
+
+// Stale reports whether loc's media has outlived its TTL or was generated on
+// an earlier local date. Without a known timezone only the TTL applies.
+func (p CachePolicy) Stale(loc *Location, now time.Time) bool {
+	ttl := p.TTL(loc)
+	if ttl == 0 {
+		return false
+	}
+	if now.Sub(loc.LastUpdated) >= ttl {
+		return true
+	}
+
+	zone := loc.Timezone
+	if zone == "" && loc.Forecast != nil {
+		zone = loc.Forecast.Timezone
+	}
+	tz, err := time.LoadLocation(zone)
+	if zone == "" || err != nil {
+		return false
+	}
+	return localDate(loc.LastUpdated.In(tz)) != localDate(now.In(tz))
+}
+
+// StaleAgainst is Stale given a fresh forecast for loc (nil if the lookup
+// failed). Media that Stale keeps is also stale if loc records the forecast
+// it was generated from and the weather has changed materially since, so the
+// TTL and local midnight remain upper bounds.
+func (p CachePolicy) StaleAgainst(loc *Location, fresh *weather.Forecast, now time.Time) bool {
+	if p.Stale(loc, now) {
+		return true
+	}
+	if p.TTL(loc) == 0 || fresh == nil || loc.Fingerprint == nil {
+		return false
+	}
+
+	threshold := p.ChangeThreshold
+	if threshold == 0 {
+		threshold = DefaultChangeThreshold
+	}
+	return loc.Fingerprint.ChangedMaterially(fresh.Fingerprint(), threshold)
+}
+
+// ServeStale reports whether stale media of loc may still be shown while it
+// is regenerated.
+func (p CachePolicy) ServeStale(loc *Location, now time.Time) bool {
+	return p.MaxStale > 0 && loc.ImageURL != "" && now.Sub(loc.LastUpdated) < p.MaxStale
+}
+
+func localDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"banana-weather/pkg/weather"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	tz, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone database unavailable: %v", err)
+	}
+	return tz
+}
+
+func TestCachePolicyStale(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	// 23:30 and 00:30 in New York are 12:30 and 13:30 of the same day in Tokyo.
+	beforeMidnight := time.Date(2026, 10, 16, 23, 30, 0, 0, newYork)
+	afterMidnight := beforeMidnight.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		policy CachePolicy
+		loc    Location
+		now    time.Time
+		want   bool
+	}{
+		{
+			name: "fresh",
+			loc:  Location{LastUpdated: beforeMidnight.Add(-time.Hour), Timezone: "America/New_York"},
+			now:  beforeMidnight,
+			want: false,
+		},
+		{
+			name: "past the default TTL",
+			loc:  Location{LastUpdated: beforeMidnight.Add(-DefaultUserTTL)},
+			now:  beforeMidnight,
+			want: true,
+		},
+		{
+			name:   "past a custom TTL",
+			policy: CachePolicy{UserTTL: 30 * time.Minute},
+			loc:    Location{LastUpdated: beforeMidnight.Add(-time.Hour)},
+			now:    beforeMidnight,
+			want:   true,
+		},
+		{
+			name: "past local midnight",
+			loc:  Location{LastUpdated: beforeMidnight, Timezone: "America/New_York"},
+			now:  afterMidnight,
+			want: true,
+		},
+		{
+			name: "same local date elsewhere",
+			loc:  Location{LastUpdated: beforeMidnight, Timezone: "Asia/Tokyo"},
+			now:  afterMidnight,
+			want: false,
+		},
+		{
+			name: "timezone of the forecast",
+			loc:  Location{LastUpdated: beforeMidnight, Forecast: &weather.Forecast{Timezone: "America/New_York"}},
+			now:  afterMidnight,
+			want: true,
+		},
+		{
+			name: "unknown timezone",
+			loc:  Location{LastUpdated: beforeMidnight},
+			now:  afterMidnight,
+			want: false,
+		},
+		{
+			name: "invalid timezone",
+			loc:  Location{LastUpdated: beforeMidnight, Timezone: "Mars/Olympus_Mons"},
+			now:  afterMidnight,
+			want: false,
+		},
+		{
+			name: "preset never expires by default",
+			loc:  Location{IsPreset: true, LastUpdated: beforeMidnight.AddDate(-1, 0, 0), Timezone: "America/New_York"},
+			now:  afterMidnight,
+			want: false,
+		},
+		{
+			name:   "preset past its TTL",
+			policy: CachePolicy{PresetTTL: 24 * time.Hour},
+			loc:    Location{IsPreset: true, LastUpdated: beforeMidnight.Add(-25 * time.Hour)},
+			now:    beforeMidnight,
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Stale(&tt.loc, tt.now); got != tt.want {
+				t.Errorf("Stale = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCachePolicyStaleAgainst(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	generated := weather.Forecast{Date: "2026-10-16", ConditionCode: 3, High: 20, Low: 10}
+	with := func(change func(f *weather.Forecast)) *weather.Forecast {
+		f := generated
+		change(&f)
+		return &f
+	}
+	recent := Location{LastUpdated: now.Add(-time.Hour), Timezone: "UTC", Fingerprint: generated.Fingerprint()}
+	old := recent
+	old.LastUpdated = now.Add(-DefaultUserTTL)
+	yesterday := recent
+	yesterday.LastUpdated = now.Add(-13 * time.Hour)
+	preset := old
+	preset.IsPreset = true
+
+	tests := []struct {
+		name   string
+		policy CachePolicy
+		loc    Location
+		fresh  *weather.Forecast
+		want   bool
+	}{
+		{"unchanged weather", CachePolicy{}, recent, &generated, false},
+		{"small change", CachePolicy{}, recent, with(func(f *weather.Forecast) { f.High += 2 }), false},
+		{"high moved", CachePolicy{}, recent, with(func(f *weather.Forecast) { f.High += 4 }), true},
+		{"low moved", CachePolicy{}, recent, with(func(f *weather.Forecast) { f.Low -= 3.5 }), true},
+		{"custom threshold", CachePolicy{ChangeThreshold: 5}, recent, with(func(f *weather.Forecast) { f.High += 4 }), false},
+		{"new condition", CachePolicy{}, recent, with(func(f *weather.Forecast) { f.ConditionCode = 61 }), true},
+		{"new date", CachePolicy{}, recent, with(func(f *weather.Forecast) { f.Date = "2026-10-17" }), true},
+		// The forecast only expires media early; the TTL and midnight still apply.
+		{"unchanged weather past the TTL", CachePolicy{}, old, &generated, true},
+		{"unchanged weather past a custom TTL", CachePolicy{UserTTL: 30 * time.Minute}, recent, &generated, true},
+		{"unchanged weather past midnight", CachePolicy{UserTTL: 24 * time.Hour}, yesterday, &generated, true},
+		{"no fresh forecast within the TTL", CachePolicy{}, recent, nil, false},
+		{"no fresh forecast past the TTL", CachePolicy{}, old, nil, true},
+		{"no fingerprint", CachePolicy{}, Location{LastUpdated: recent.LastUpdated}, with(func(f *weather.Forecast) { f.ConditionCode = 61 }), false},
+		{"preset that never expires", CachePolicy{}, preset, with(func(f *weather.Forecast) { f.ConditionCode = 61 }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.StaleAgainst(&tt.loc, tt.fresh, now); got != tt.want {
+				t.Errorf("StaleAgainst = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"math"
+)
+
+// CellDegrees is the size of the grid cells user locations are indexed by
+// (Location.Cell), so the ones near a point can be found with an equality
+// query.
+const CellDegrees = 0.5
+
+// MaxMatchRadiusKm bounds CachePolicy.MatchRadiusKm, so that CellsAround
+// never needs more cells than a Firestore "in" query allows.
+const MaxMatchRadiusKm = 50
+
+// maxCellsAcross is how many cells east and west of a point CellsAround looks
+// at most. Near the poles cells are narrower than the radius; the few
+// locations there are matched less generously.
+const maxCellsAcross = 4
+
+// HasCoordinates reports whether loc's coordinates are known. Locations
+// cached before they were recorded are at 0,0.
+func (l *Location) HasCoordinates() bool {
+	return l.Lat != 0 || l.Lng != 0
+}
+
+// Cell returns the grid cell of a point, e.g. "75:-245" for San Francisco.
+func Cell(lat, lng float64) string {
+	return fmt.Sprintf("%d:%d", int(math.Floor(lat/CellDegrees)), int(math.Floor(lng/CellDegrees)))
+}
+
+// cellOf returns the cell to index loc by, or "" if its coordinates are
+// unknown.
+func cellOf(loc Location) string {
+	if !loc.HasCoordinates() {
+		return ""
+	}
+	return Cell(loc.Lat, loc.Lng)
+}
+
+// CellsAround returns the cells that may hold points within radiusKm (at
+// most MaxMatchRadiusKm) of lat, lng.
+func CellsAround(lat, lng, radiusKm float64) []string {
+	const kmPerDegree = 111.2
+	row := int(math.Floor(lat / CellDegrees))
+	col := int(math.Floor(lng / CellDegrees))
+
+	rows := int(math.Ceil(radiusKm / (kmPerDegree * CellDegrees)))
+	cols := maxCellsAcross
+	if w := kmPerDegree * CellDegrees * math.Cos(lat*math.Pi/180); w > 0 {
+		cols = min(int(math.Ceil(radiusKm/w)), maxCellsAcross)
+	}
+
+	colsAround := int(360 / CellDegrees)
+	var cells []string
+	for r := row - rows; r <= row+rows; r++ {
+		for c := col - cols; c <= col+cols; c++ {
+			// Wrap around the antimeridian.
+			wrapped := (c+colsAround/2)%colsAround - colsAround/2
+			if wrapped < -colsAround/2 {
+				wrapped += colsAround
+			}
+			cells = append(cells, fmt.Sprintf("%d:%d", r, wrapped))
+		}
+	}
+	return cells
+}
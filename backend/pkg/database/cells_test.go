@@ -0,0 +1,66 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCellsAround(t *testing.T) {
+	type point struct{ lat, lng float64 }
+	tests := []struct {
+		name         string
+		lat, lng     float64
+		radiusKm     float64
+		wantCells    int
+		mustInclude  []point
+		mustNotMatch []point
+	}{
+		{
+			name: "San Francisco", lat: 37.77, lng: -122.42, radiusKm: 10,
+			wantCells:    9,
+			mustInclude:  []point{{37.77, -122.42}, {37.80, -122.27}, {37.70, -122.48}},
+			mustNotMatch: []point{{38.58, -121.49}}, // Sacramento
+		},
+		{
+			name: "cell corner", lat: 0.01, lng: 0.01, radiusKm: 5,
+			wantCells:   9,
+			mustInclude: []point{{-0.01, -0.01}, {0.01, -0.01}, {-0.01, 0.01}},
+		},
+		{
+			name: "antimeridian", lat: -17.7, lng: 179.95, radiusKm: 10,
+			wantCells:   9,
+			mustInclude: []point{{-17.7, 179.95}, {-17.7, -179.95}},
+		},
+		{
+			name: "maximum radius", lat: 51.5, lng: -0.12, radiusKm: MaxMatchRadiusKm,
+			wantCells:   15,
+			mustInclude: []point{{51.9, -0.12}, {51.5, 0.5}},
+		},
+		{
+			name: "near the pole", lat: 89.9, lng: 10, radiusKm: MaxMatchRadiusKm,
+			wantCells: 3 * (2*maxCellsAcross + 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cells := CellsAround(tt.lat, tt.lng, tt.radiusKm)
+			if len(cells) != tt.wantCells {
+				t.Errorf("got %d cells, want %d: %v", len(cells), tt.wantCells, cells)
+			}
+			// Firestore "in" queries take at most 30 values.
+			if len(cells) > 30 {
+				t.Errorf("got %d cells, more than a Firestore query allows", len(cells))
+			}
+			for _, p := range tt.mustInclude {
+				if c := Cell(p.lat, p.lng); !slices.Contains(cells, c) {
+					t.Errorf("cell %s of %v missing from %v", c, p, cells)
+				}
+			}
+			for _, p := range tt.mustNotMatch {
+				if c := Cell(p.lat, p.lng); slices.Contains(cells, c) {
+					t.Errorf("cell %s of %v is included, want it left out", c, p)
+				}
+			}
+		})
+	}
+}
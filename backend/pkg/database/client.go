@@ -2,15 +2,108 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"banana-weather/pkg/weather"
+
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNotFound is returned (wrapped) by every store when a document does not
+// exist.
+var ErrNotFound = errors.New("not found")
+
+// Namespace is where a location is stored. Curated presets and cached user
+// searches are kept apart, so a user search can never overwrite a preset,
+// whatever its ID. The values are the Firestore collection names.
+type Namespace string
+
+const (
+	PresetNamespace Namespace = "locations"
+	UserNamespace   Namespace = "user_locations"
 )
 
+// LocationStore persists presets and cached user locations.
+// Client is the Firestore implementation.
+type LocationStore interface {
+	GetPresets(ctx context.Context) ([]Location, error)
+	// ListLocations returns every location, presets and cached user
+	// searches alike: presets first, each namespace ordered by ID. A
+	// location that can't be parsed is an error rather than skipped, so
+	// callers never mistake it for a missing one.
+	ListLocations(ctx context.Context) ([]Location, error)
+	GetLocation(ctx context.Context, ns Namespace, id string) (*Location, error)
+	// UpsertLocation stores loc in loc.Namespace().
+	UpsertLocation(ctx context.Context, loc Location) error
+	// UpdateLocation applies fn to the stored location id of ns atomically,
+	// keeping LastUpdated. Nothing is written if fn returns false. fn may
+	// be called more than once.
+	UpdateLocation(ctx context.Context, ns Namespace, id string, fn func(loc *Location) bool) error
+	// MoveLocation replaces location oldID of ns with loc (which may have
+	// the same ID) atomically, keeping loc.LastUpdated. It is for
+	// migrations.
+	MoveLocation(ctx context.Context, ns Namespace, oldID string, loc Location) error
+	DeleteLocation(ctx context.Context, ns Namespace, id string) error
+	// FindLocationByPlaceID returns the user location of a Maps place.
+	FindLocationByPlaceID(ctx context.Context, placeID string) (*Location, error)
+	// ListLocationsInCells returns the user locations in any of the given
+	// grid cells (see CellsAround).
+	ListLocationsInCells(ctx context.Context, cells []string) ([]Location, error)
+	// ListRecentLocations returns up to limit user locations, most recently
+	// updated first.
+	ListRecentLocations(ctx context.Context, limit int) ([]Location, error)
+	// GetPendingVideos returns the locations of either namespace with a
+	// video still being generated (VideoOpName set), so they can be resumed
+	// after a restart.
+	GetPendingVideos(ctx context.Context) ([]Location, error)
+	Close() error
+}
+
+// Store is everything the server persists. Client, BoltClient and
+// MemoryStore all implement it.
+type Store interface {
+	LocationStore
+	JobStore
+	LeaseStore
+	CounterStore
+	APIKeyStore
+	CollisionStore
+	GeocodeStore
+}
+
+// NewStore returns the Store selected by DATABASE_BACKEND:
+//   - "firestore" (default): see NewClient.
+//   - "bolt": an embedded database file at BOLT_PATH (default "banana-weather.db").
+func NewStore(ctx context.Context) (Store, error) {
+	switch backend := os.Getenv("DATABASE_BACKEND"); backend {
+	case "", "firestore":
+		c, err := NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	case "bolt":
+		path := os.Getenv("BOLT_PATH")
+		if path == "" {
+			path = "banana-weather.db"
+		}
+		c, err := NewBoltClient(path)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown DATABASE_BACKEND %q (want firestore or bolt)", backend)
+	}
+}
+
 type Client struct {
 	fs *firestore.Client
 }
@@ -20,7 +113,7 @@ func NewClient(ctx context.Context) (*Client, error) {
 	if projectID == "" {
 		projectID = os.Getenv("PROJECT_ID")
 	}
-	
+
 	databaseID := os.Getenv("FIRESTORE_DATABASE")
 	if databaseID == "" {
 		// Default to standard DB if not set, but we prefer explicit
@@ -46,22 +139,46 @@ func (c *Client) Close() error {
 // -- Models --
 
 type Location struct {
-	ID          string    `firestore:"id" json:"id"`
-	Name        string    `firestore:"name" json:"name"`         // Display Name
-	Category    string    `firestore:"category" json:"category"` // Grouping
-	CityQuery   string    `firestore:"city_query" json:"city_query"` // Original input
-	ImageURL    string    `firestore:"image_url" json:"image_url"`
-	VideoURL    string    `firestore:"video_url" json:"video_url"`
-	IsPreset    bool      `firestore:"is_preset" json:"is_preset"` // Admin managed?
-	LastUpdated time.Time `firestore:"last_updated" json:"last_updated"`
+	ID          string               `firestore:"id" json:"id"`
+	Name        string               `firestore:"name" json:"name"`                             // Display Name
+	Category    string               `firestore:"category" json:"category"`                     // Grouping
+	CityQuery   string               `firestore:"city_query" json:"city_query"`                 // Original input
+	PlaceID     string               `firestore:"place_id,omitempty" json:"place_id,omitempty"` // Maps place ID (user locations)
+	Lat         float64              `firestore:"lat,omitempty" json:"lat,omitempty"`
+	Lng         float64              `firestore:"lng,omitempty" json:"lng,omitempty"`
+	Cell        string               `firestore:"cell,omitempty" json:"cell,omitempty"` // Of Lat/Lng, set on save; see Cell
+	ImageURL    string               `firestore:"image_url" json:"image_url"`
+	VideoURL    string               `firestore:"video_url" json:"video_url"`
+	Forecast    *weather.Forecast    `firestore:"forecast,omitempty" json:"forecast,omitempty"`       // Weather depicted in the image
+	Fingerprint *weather.Fingerprint `firestore:"fingerprint,omitempty" json:"fingerprint,omitempty"` // Of Forecast, for change detection
+	IsPreset    bool                 `firestore:"is_preset" json:"is_preset"`                         // Admin managed?
+	LastUpdated time.Time            `firestore:"last_updated" json:"last_updated"`
+	Timezone    string               `firestore:"timezone,omitempty" json:"timezone,omitempty"` // IANA zone, for midnight expiry
+
+	// Pending Veo operation for VideoURL; cleared once the video is saved.
+	VideoOpName      string     `firestore:"video_op_name,omitempty" json:"video_op_name,omitempty"`
+	VideoOpStartedAt *time.Time `firestore:"video_op_started_at,omitempty" json:"video_op_started_at,omitempty"`
+
+	// Extra prompt context presets are generated with (e.g. for fictional places).
+	PromptContext string `firestore:"prompt_context,omitempty" json:"prompt_context,omitempty"`
+}
+
+// Namespace returns where loc is stored.
+func (l *Location) Namespace() Namespace {
+	if l.IsPreset {
+		return PresetNamespace
+	}
+	return UserNamespace
 }
 
 // -- Methods --
 
-// GetPresets returns all locations where is_preset = true.
+// GetPresets returns all locations where is_preset = true. Documents in the
+// preset namespace without it are user searches written before the
+// namespaces were split (see cmd/repair_presets).
 func (c *Client) GetPresets(ctx context.Context) ([]Location, error) {
 	var presets []Location
-	iter := c.fs.Collection("locations").Where("is_preset", "==", true).Documents(ctx)
+	iter := c.fs.Collection(string(PresetNamespace)).Where("is_preset", "==", true).Documents(ctx)
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
@@ -80,26 +197,176 @@ func (c *Client) GetPresets(ctx context.Context) ([]Location, error) {
 	return presets, nil
 }
 
-// UpsertLocation creates or updates a location document.
+// ListLocations returns the documents of both namespaces, presets first,
+// each ordered by document ID.
+func (c *Client) ListLocations(ctx context.Context) ([]Location, error) {
+	var locations []Location
+	for _, ns := range []Namespace{PresetNamespace, UserNamespace} {
+		iter := c.fs.Collection(string(ns)).Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			var loc Location
+			if err := doc.DataTo(&loc); err != nil {
+				return nil, fmt.Errorf("failed to parse location doc %s/%s: %w", ns, doc.Ref.ID, err)
+			}
+			locations = append(locations, loc)
+		}
+	}
+	return locations, nil
+}
+
+// ListRecentLocations returns the user location documents with the latest
+// last_updated.
+func (c *Client) ListRecentLocations(ctx context.Context, limit int) ([]Location, error) {
+	var locations []Location
+	iter := c.fs.Collection(string(UserNamespace)).OrderBy("last_updated", firestore.Desc).Limit(limit).Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var loc Location
+		if err := doc.DataTo(&loc); err != nil {
+			log.Printf("Failed to parse location doc %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		locations = append(locations, loc)
+	}
+	return locations, nil
+}
+
+// GetPendingVideos returns the location documents of both namespaces with a
+// video_op_name.
+func (c *Client) GetPendingVideos(ctx context.Context) ([]Location, error) {
+	var pending []Location
+	for _, ns := range []Namespace{PresetNamespace, UserNamespace} {
+		iter := c.fs.Collection(string(ns)).Where("video_op_name", ">", "").Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			var loc Location
+			if err := doc.DataTo(&loc); err != nil {
+				log.Printf("Failed to parse location doc %s/%s: %v", ns, doc.Ref.ID, err)
+				continue
+			}
+			pending = append(pending, loc)
+		}
+	}
+	return pending, nil
+}
+
+// FindLocationByPlaceID returns the user location document with place_id.
+func (c *Client) FindLocationByPlaceID(ctx context.Context, placeID string) (*Location, error) {
+	iter := c.fs.Collection(string(UserNamespace)).Where("place_id", "==", placeID).Limit(1).Documents(ctx)
+	defer iter.Stop()
+	doc, err := iter.Next()
+	if err == iterator.Done {
+		return nil, fmt.Errorf("location of place %s: %w", placeID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var loc Location
+	if err := doc.DataTo(&loc); err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+// ListLocationsInCells returns the user location documents whose cell is
+// one of cells (at most 30, the limit of an "in" query).
+func (c *Client) ListLocationsInCells(ctx context.Context, cells []string) ([]Location, error) {
+	if len(cells) == 0 {
+		return nil, nil
+	}
+	var locations []Location
+	iter := c.fs.Collection(string(UserNamespace)).Where("cell", "in", cells).Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var loc Location
+		if err := doc.DataTo(&loc); err != nil {
+			log.Printf("Failed to parse location doc %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		locations = append(locations, loc)
+	}
+	return locations, nil
+}
+
+// UpsertLocation creates or replaces a location document in its namespace.
 func (c *Client) UpsertLocation(ctx context.Context, loc Location) error {
 	// Use ID as document ID if possible, ensuring uniqueness.
 	// If ID is empty (new user search), maybe hash the city query?
 	// For presets, ID is set.
-	
+
 	if loc.ID == "" {
 		return fmt.Errorf("location ID is required")
 	}
 
 	loc.LastUpdated = time.Now()
-	_, err := c.fs.Collection("locations").Doc(loc.ID).Set(ctx, loc)
+	loc.Cell = cellOf(loc)
+	_, err := c.fs.Collection(string(loc.Namespace())).Doc(loc.ID).Set(ctx, loc)
+	return err
+}
+
+// UpdateLocation applies fn to a location document in a transaction.
+func (c *Client) UpdateLocation(ctx context.Context, ns Namespace, id string, fn func(loc *Location) bool) error {
+	ref := c.fs.Collection(string(ns)).Doc(id)
+	return c.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("location %s: %w", id, ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		var loc Location
+		if err := doc.DataTo(&loc); err != nil {
+			return err
+		}
+		if !fn(&loc) {
+			return nil
+		}
+		loc.Cell = cellOf(loc)
+		return tx.Set(ref, loc)
+	})
+}
+
+// DeleteLocation deletes a location document. Deleting a missing document is
+// not an error.
+func (c *Client) DeleteLocation(ctx context.Context, ns Namespace, id string) error {
+	_, err := c.fs.Collection(string(ns)).Doc(id).Delete(ctx)
 	return err
 }
 
-// GetLocation retrieves a location by ID.
-func (c *Client) GetLocation(ctx context.Context, id string) (*Location, error) {
-	doc, err := c.fs.Collection("locations").Doc(id).Get(ctx)
+// GetLocation retrieves a location by namespace and ID.
+func (c *Client) GetLocation(ctx context.Context, ns Namespace, id string) (*Location, error) {
+	doc, err := c.fs.Collection(string(ns)).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("location %s: %w", id, ErrNotFound)
+	}
 	if err != nil {
-		return nil, err // Returns NotFound status code if missing
+		return nil, err
 	}
 	var loc Location
 	if err := doc.DataTo(&loc); err != nil {
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Counter is a fixed-window usage counter (see CounterStore).
+type Counter struct {
+	Count   int       `firestore:"count" json:"count"`
+	ResetAt time.Time `firestore:"reset_at" json:"reset_at"` // End of the window; also for a Firestore TTL policy
+}
+
+// CounterStore keeps the fixed-window counters used for rate limiting.
+type CounterStore interface {
+	// IncrementCounter adds one to the counter for key unless it has already
+	// reached limit, in which case it reports false. A counter whose window
+	// has ended starts over with a new window of the given length.
+	IncrementCounter(ctx context.Context, key string, limit int, window time.Duration) (Counter, bool, error)
+}
+
+// incrementCounter applies IncrementCounter to a counter loaded from a store.
+func incrementCounter(c Counter, limit int, window time.Duration, now time.Time) (Counter, bool) {
+	if !now.Before(c.ResetAt) {
+		c = Counter{ResetAt: now.Add(window)}
+	}
+	if c.Count >= limit {
+		return c, false
+	}
+	c.Count++
+	return c, true
+}
+
+// IncrementCounter updates the counter document in a transaction.
+func (c *Client) IncrementCounter(ctx context.Context, key string, limit int, window time.Duration) (Counter, bool, error) {
+	ref := c.fs.Collection("rate_limits").Doc(key)
+	var counter Counter
+	var ok bool
+	err := c.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		var current Counter
+		if err == nil {
+			if err := doc.DataTo(&current); err != nil {
+				return err
+			}
+		}
+		counter, ok = incrementCounter(current, limit, window, time.Now())
+		if !ok {
+			return nil
+		}
+		return tx.Set(ref, counter)
+	})
+	if err != nil {
+		return Counter{}, false, err
+	}
+	return counter, ok, nil
+}
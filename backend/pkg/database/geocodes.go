@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Geocode is a cached geocoding result (see pkg/geocache).
+type Geocode struct {
+	Key       string          `firestore:"key" json:"key"`                           // Normalized query, place ID or rounded coordinates
+	NotFound  bool            `firestore:"not_found" json:"not_found"`               // Negative entry: the geocoder found nothing
+	Places    []GeocodedPlace `firestore:"places,omitempty" json:"places,omitempty"` // Best match first
+	ExpiresAt time.Time       `firestore:"expires_at" json:"expires_at"`             // Also for a Firestore TTL policy
+}
+
+// GeocodedPlace is one place of a cached geocoding result.
+type GeocodedPlace struct {
+	PlaceID string  `firestore:"place_id" json:"place_id"`
+	Name    string  `firestore:"name" json:"name"`
+	Country string  `firestore:"country,omitempty" json:"country,omitempty"`
+	Lat     float64 `firestore:"lat" json:"lat"`
+	Lng     float64 `firestore:"lng" json:"lng"`
+}
+
+// GeocodeStore persists geocoding results across restarts and instances.
+// Keys are chosen by the caller and must be valid document IDs.
+type GeocodeStore interface {
+	// GetGeocode returns the entry for key, expired or not.
+	GetGeocode(ctx context.Context, key string) (*Geocode, error)
+	PutGeocode(ctx context.Context, g Geocode) error
+}
+
+// GetGeocode retrieves a geocode document.
+func (c *Client) GetGeocode(ctx context.Context, key string) (*Geocode, error) {
+	doc, err := c.fs.Collection("geocodes").Doc(key).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("geocode %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var g Geocode
+	if err := doc.DataTo(&g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// PutGeocode creates or replaces a geocode document.
+func (c *Client) PutGeocode(ctx context.Context, g Geocode) error {
+	if g.Key == "" {
+		return fmt.Errorf("geocode key is required")
+	}
+	_, err := c.fs.Collection("geocodes").Doc(g.Key).Set(ctx, g)
+	return err
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"banana-weather/pkg/slug"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Lengths of the place ID hash in user location IDs: the usual one, and the
+// one used instead when the usual ID is already taken by another place.
+const (
+	idHashLen          = 8
+	collisionIDHashLen = 16
+)
+
+// UserLocationID returns the usual ID of a user location: a slug of its name,
+// so the database stays readable, and a hash of its place ID, so that places
+// whose names slug alike (or not at all) still get IDs of their own, e.g.
+// "東京都_日本_1b2c3d4e". Without a place ID the name is hashed instead.
+func UserLocationID(name, placeID string) string {
+	return slug.Make(name) + "_" + slug.Hash(hashKey(name, placeID), idHashLen)
+}
+
+func hashKey(name, placeID string) string {
+	if placeID == "" {
+		return name
+	}
+	return placeID
+}
+
+// Collision records a user location ID that two places hashed to.
+type Collision struct {
+	ID           string    `firestore:"id" json:"id"` // The contested ID
+	PlaceID      string    `firestore:"place_id" json:"place_id"`
+	Name         string    `firestore:"name" json:"name"`
+	OtherPlaceID string    `firestore:"other_place_id" json:"other_place_id"` // Place holding ID
+	OtherName    string    `firestore:"other_name" json:"other_name"`
+	DetectedAt   time.Time `firestore:"detected_at" json:"detected_at"`
+}
+
+// CollisionStore records location ID collisions for review.
+type CollisionStore interface {
+	RecordCollision(ctx context.Context, c Collision) error
+}
+
+// ResolveUserLocationID returns the ID the place is cached under: its usual
+// ID (see UserLocationID), unless a location of another place already holds
+// it. Then the collision is logged and recorded, if db is also a
+// CollisionStore, and an ID with a longer hash is returned.
+func ResolveUserLocationID(ctx context.Context, db LocationStore, name, placeID string) (string, error) {
+	id := UserLocationID(name, placeID)
+	existing, err := db.GetLocation(ctx, UserNamespace, id)
+	if errors.Is(err, ErrNotFound) {
+		return id, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if existing.PlaceID == "" || existing.PlaceID == placeID {
+		return id, nil
+	}
+
+	log.Printf("Location ID collision: %s is held by %q (%s), not %q (%s)", id, existing.Name, existing.PlaceID, name, placeID)
+	if collisions, ok := db.(CollisionStore); ok {
+		err := collisions.RecordCollision(ctx, Collision{
+			ID:           id,
+			PlaceID:      placeID,
+			Name:         name,
+			OtherPlaceID: existing.PlaceID,
+			OtherName:    existing.Name,
+			DetectedAt:   time.Now(),
+		})
+		if err != nil {
+			log.Printf("Failed to record collision on %s: %v", id, err)
+		}
+	}
+	return slug.Make(name) + "_" + slug.Hash(hashKey(name, placeID), collisionIDHashLen), nil
+}
+
+// RecordCollision stores c, replacing an earlier record of the same ID.
+func (c *Client) RecordCollision(ctx context.Context, col Collision) error {
+	if col.ID == "" {
+		return fmt.Errorf("collision ID is required")
+	}
+	_, err := c.fs.Collection("location_collisions").Doc(col.ID).Set(ctx, col)
+	return err
+}
+
+// MoveLocation replaces the document oldID of ns with loc in one
+// transaction, keeping loc.LastUpdated.
+func (c *Client) MoveLocation(ctx context.Context, ns Namespace, oldID string, loc Location) error {
+	if loc.ID == "" {
+		return fmt.Errorf("location ID is required")
+	}
+	if loc.Namespace() != ns {
+		return fmt.Errorf("location %s does not belong in %s", loc.ID, ns)
+	}
+	loc.Cell = cellOf(loc)
+	coll := c.fs.Collection(string(ns))
+	return c.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Set(coll.Doc(loc.ID), loc); err != nil {
+			return err
+		}
+		if oldID == loc.ID {
+			return nil
+		}
+		return tx.Delete(coll.Doc(oldID))
+	})
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Job stages. Terminal stages are JobDone and JobFailed.
+const (
+	JobQueued          = "queued"
+	JobResolving       = "resolving"
+	JobGeneratingImage = "generating_image"
+	JobGeneratingVideo = "generating_video"
+	JobDone            = "done"
+	JobFailed          = "failed"
+)
+
+// Job records the progress of one weather generation so that clients can
+// reconnect to its event stream (see pkg/jobs).
+type Job struct {
+	ID          string     `firestore:"id" json:"id"`
+	City        string     `firestore:"city" json:"city"` // Query as received (city name or "lat,lng")
+	LocationID  string     `firestore:"location_id" json:"location_id,omitempty"`
+	Stage       string     `firestore:"stage" json:"stage"`
+	ImageURL    string     `firestore:"image_url" json:"image_url,omitempty"`
+	VideoURL    string     `firestore:"video_url" json:"video_url,omitempty"`
+	VideoOpName string     `firestore:"video_op_name" json:"video_op_name,omitempty"` // Veo long-running operation
+	Error       string     `firestore:"error" json:"error,omitempty"`
+	Events      []JobEvent `firestore:"events" json:"events"`
+	CreatedAt   time.Time  `firestore:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `firestore:"updated_at" json:"updated_at"`
+	ExpiresAt   time.Time  `firestore:"expires_at" json:"expires_at"` // For a Firestore TTL policy
+}
+
+// jobSweepInterval is how often the embedded stores delete expired jobs,
+// which Firestore leaves to its TTL policy.
+const jobSweepInterval = time.Hour
+
+// Expired reports whether the job is past ExpiresAt. Stores treat expired
+// jobs as deleted, whether or not they have been removed yet.
+func (j *Job) Expired(now time.Time) bool {
+	return !j.ExpiresAt.IsZero() && !now.Before(j.ExpiresAt)
+}
+
+// Finished reports whether the job has reached a terminal stage.
+func (j *Job) Finished() bool {
+	return j.Stage == JobDone || j.Stage == JobFailed
+}
+
+// JobEvent is one SSE event emitted by a job. IDs start at 1 and increase.
+type JobEvent struct {
+	ID      int    `firestore:"id" json:"id"`
+	Event   string `firestore:"event" json:"event"`
+	Data    string `firestore:"data" json:"data"`
+	Omitted bool   `firestore:"omitted,omitempty" json:"omitted,omitempty"` // Data too large to persist
+}
+
+// JobStore persists generation jobs.
+type JobStore interface {
+	GetJob(ctx context.Context, id string) (*Job, error)
+	UpsertJob(ctx context.Context, job Job) error
+}
+
+// GetJob retrieves a job by ID.
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	doc, err := c.fs.Collection("jobs").Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := doc.DataTo(&job); err != nil {
+		return nil, err
+	}
+	if job.Expired(time.Now()) {
+		// The TTL policy deletes documents up to a day late.
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+	return &job, nil
+}
+
+// UpsertJob creates or replaces a job document.
+func (c *Client) UpsertJob(ctx context.Context, job Job) error {
+	if job.ID == "" {
+		return fmt.Errorf("job ID is required")
+	}
+	_, err := c.fs.Collection("jobs").Doc(job.ID).Set(ctx, job)
+	return err
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Lease grants one holder exclusive use of a key until ExpiresAt.
+type Lease struct {
+	Holder    string    `firestore:"holder" json:"holder"`
+	ExpiresAt time.Time `firestore:"expires_at" json:"expires_at"` // Also for a Firestore TTL policy
+}
+
+func (l *Lease) heldByOther(holder string, now time.Time) bool {
+	return l.Holder != holder && now.Before(l.ExpiresAt)
+}
+
+// LeaseStore elects a single holder for a key across server instances (e.g.
+// one job generating a location at a time).
+type LeaseStore interface {
+	// AcquireLease takes the lease on key for holder unless someone else
+	// holds an unexpired lease. It returns the holder after the call, which is
+	// holder itself if the lease was acquired (or renewed).
+	AcquireLease(ctx context.Context, key, holder string, ttl time.Duration) (string, error)
+	// ReleaseLease gives up the lease if holder still holds it.
+	ReleaseLease(ctx context.Context, key, holder string) error
+}
+
+// AcquireLease takes or renews the lease document in a transaction.
+func (c *Client) AcquireLease(ctx context.Context, key, holder string, ttl time.Duration) (string, error) {
+	ref := c.fs.Collection("leases").Doc(key)
+	var current string
+	err := c.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		now := time.Now()
+		if err == nil {
+			var lease Lease
+			if err := doc.DataTo(&lease); err != nil {
+				return err
+			}
+			if lease.heldByOther(holder, now) {
+				current = lease.Holder
+				return nil
+			}
+		}
+		current = holder
+		return tx.Set(ref, Lease{Holder: holder, ExpiresAt: now.Add(ttl)})
+	})
+	if err != nil {
+		return "", err
+	}
+	return current, nil
+}
+
+// ReleaseLease deletes the lease document if holder still holds it.
+func (c *Client) ReleaseLease(ctx context.Context, key, holder string) error {
+	ref := c.fs.Collection("leases").Doc(key)
+	return c.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var lease Lease
+		if err := doc.DataTo(&lease); err != nil {
+			return err
+		}
+		if lease.Holder != holder {
+			return nil
+		}
+		return tx.Delete(ref)
+	})
+}
@@ -0,0 +1,378 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process LocationStore used for offline development.
+// Data is lost when the process exits.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	locations  map[Namespace]map[string]Location
+	jobs       map[string]Job
+	leases     map[string]Lease
+	counters   map[string]Counter
+	lastSweep  time.Time
+	jobsSwept  time.Time
+	apiKeys    map[string]APIKey
+	collisions map[string]Collision
+	geocodes   map[string]Geocode
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		locations:  map[Namespace]map[string]Location{PresetNamespace: {}, UserNamespace: {}},
+		jobs:       make(map[string]Job),
+		leases:     make(map[string]Lease),
+		counters:   make(map[string]Counter),
+		apiKeys:    make(map[string]APIKey),
+		collisions: make(map[string]Collision),
+		geocodes:   make(map[string]Geocode),
+	}
+}
+
+// Close is a no-op; it exists to satisfy LocationStore.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// GetPresets returns all locations where IsPreset is true, ordered by ID.
+func (m *MemoryStore) GetPresets(ctx context.Context) ([]Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	presets := []Location{}
+	for _, loc := range m.locations[PresetNamespace] {
+		if loc.IsPreset {
+			presets = append(presets, loc)
+		}
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].ID < presets[j].ID })
+	return presets, nil
+}
+
+// ListLocations returns all locations, presets first, each namespace ordered
+// by ID.
+func (m *MemoryStore) ListLocations(ctx context.Context) ([]Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var locations []Location
+	for _, ns := range []Namespace{PresetNamespace, UserNamespace} {
+		locs := slices.Collect(maps.Values(m.locations[ns]))
+		sort.Slice(locs, func(i, j int) bool { return locs[i].ID < locs[j].ID })
+		locations = append(locations, locs...)
+	}
+	return locations, nil
+}
+
+// ListRecentLocations returns the user locations updated last.
+func (m *MemoryStore) ListRecentLocations(ctx context.Context, limit int) ([]Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return newest(slices.Collect(maps.Values(m.locations[UserNamespace])), limit), nil
+}
+
+// newest sorts locations most recently updated first and keeps up to limit
+// of them.
+func newest(locations []Location, limit int) []Location {
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].LastUpdated.After(locations[j].LastUpdated)
+	})
+	return locations[:min(len(locations), limit)]
+}
+
+// GetPendingVideos returns all locations with a VideoOpName, presets first.
+func (m *MemoryStore) GetPendingVideos(ctx context.Context) ([]Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var pending []Location
+	for _, ns := range []Namespace{PresetNamespace, UserNamespace} {
+		for _, loc := range m.locations[ns] {
+			if loc.VideoOpName != "" {
+				pending = append(pending, loc)
+			}
+		}
+	}
+	return pending, nil
+}
+
+// FindLocationByPlaceID returns the user location with PlaceID placeID.
+func (m *MemoryStore) FindLocationByPlaceID(ctx context.Context, placeID string) (*Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, loc := range m.locations[UserNamespace] {
+		if loc.PlaceID == placeID {
+			return &loc, nil
+		}
+	}
+	return nil, fmt.Errorf("location of place %s: %w", placeID, ErrNotFound)
+}
+
+// ListLocationsInCells returns the user locations in any of cells.
+func (m *MemoryStore) ListLocationsInCells(ctx context.Context, cells []string) ([]Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var locations []Location
+	for _, loc := range m.locations[UserNamespace] {
+		if loc.Cell != "" && slices.Contains(cells, loc.Cell) {
+			locations = append(locations, loc)
+		}
+	}
+	return locations, nil
+}
+
+// UpsertLocation creates or replaces a location in its namespace.
+func (m *MemoryStore) UpsertLocation(ctx context.Context, loc Location) error {
+	if loc.ID == "" {
+		return fmt.Errorf("location ID is required")
+	}
+
+	loc.LastUpdated = time.Now()
+	loc.Cell = cellOf(loc)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.locations[loc.Namespace()][loc.ID] = loc
+	return nil
+}
+
+// UpdateLocation applies fn to a location.
+func (m *MemoryStore) UpdateLocation(ctx context.Context, ns Namespace, id string, fn func(loc *Location) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loc, ok := m.locations[ns][id]
+	if !ok {
+		return fmt.Errorf("location %s: %w", id, ErrNotFound)
+	}
+	if fn(&loc) {
+		loc.Cell = cellOf(loc)
+		m.locations[ns][id] = loc
+	}
+	return nil
+}
+
+// MoveLocation replaces location oldID of ns with loc, keeping loc.LastUpdated.
+func (m *MemoryStore) MoveLocation(ctx context.Context, ns Namespace, oldID string, loc Location) error {
+	if loc.ID == "" {
+		return fmt.Errorf("location ID is required")
+	}
+	if loc.Namespace() != ns {
+		return fmt.Errorf("location %s does not belong in %s", loc.ID, ns)
+	}
+	loc.Cell = cellOf(loc)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.locations[ns], oldID)
+	m.locations[ns][loc.ID] = loc
+	return nil
+}
+
+// DeleteLocation removes a location.
+func (m *MemoryStore) DeleteLocation(ctx context.Context, ns Namespace, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.locations[ns], id)
+	return nil
+}
+
+// GetLocation retrieves a location by namespace and ID.
+func (m *MemoryStore) GetLocation(ctx context.Context, ns Namespace, id string) (*Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	loc, ok := m.locations[ns][id]
+	if !ok {
+		return nil, fmt.Errorf("location %s: %w", id, ErrNotFound)
+	}
+	return &loc, nil
+}
+
+// GetJob retrieves a job by ID.
+func (m *MemoryStore) GetJob(ctx context.Context, id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.Expired(time.Now()) {
+		return nil, fmt.Errorf("job %s: %w", id, ErrNotFound)
+	}
+	job.Events = append([]JobEvent(nil), job.Events...)
+	return &job, nil
+}
+
+// UpsertJob creates or replaces a job, deleting expired jobs now and then.
+func (m *MemoryStore) UpsertJob(ctx context.Context, job Job) error {
+	if job.ID == "" {
+		return fmt.Errorf("job ID is required")
+	}
+	job.Events = append([]JobEvent(nil), job.Events...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID] = job
+
+	now := time.Now()
+	if now.Sub(m.jobsSwept) > jobSweepInterval {
+		for id, j := range m.jobs {
+			if j.Expired(now) {
+				delete(m.jobs, id)
+			}
+		}
+		m.jobsSwept = now
+	}
+	return nil
+}
+
+// AcquireLease takes or renews the lease on key for holder.
+func (m *MemoryStore) AcquireLease(ctx context.Context, key, holder string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if lease, ok := m.leases[key]; ok && lease.heldByOther(holder, now) {
+		return lease.Holder, nil
+	}
+	m.leases[key] = Lease{Holder: holder, ExpiresAt: now.Add(ttl)}
+	return holder, nil
+}
+
+// ReleaseLease removes the lease on key if holder still holds it.
+func (m *MemoryStore) ReleaseLease(ctx context.Context, key, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lease, ok := m.leases[key]; ok && lease.Holder == holder {
+		delete(m.leases, key)
+	}
+	return nil
+}
+
+// IncrementCounter adds one to the counter for key unless it has reached limit.
+func (m *MemoryStore) IncrementCounter(ctx context.Context, key string, limit int, window time.Duration) (Counter, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	// Forget expired counters now and then so one-off clients don't pile up.
+	if now.Sub(m.lastSweep) > time.Minute {
+		for k, c := range m.counters {
+			if !now.Before(c.ResetAt) {
+				delete(m.counters, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	counter, ok := incrementCounter(m.counters[key], limit, window, now)
+	m.counters[key] = counter
+	return counter, ok, nil
+}
+
+// GetAPIKey retrieves an API key by ID.
+func (m *MemoryStore) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("api key %s: %w", id, ErrNotFound)
+	}
+	key.Usage = maps.Clone(key.Usage)
+	return &key, nil
+}
+
+// ListAPIKeys returns all API keys, ordered by name.
+func (m *MemoryStore) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := []APIKey{}
+	for _, key := range m.apiKeys {
+		key.Usage = maps.Clone(key.Usage)
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+// UpsertAPIKey creates or replaces an API key.
+func (m *MemoryStore) UpsertAPIKey(ctx context.Context, key APIKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("api key ID is required")
+	}
+	key.Usage = maps.Clone(key.Usage)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apiKeys[key.ID] = key
+	return nil
+}
+
+// RecordUsage adds one to the key's usage counter named kind.
+func (m *MemoryStore) RecordUsage(ctx context.Context, id string, kind string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return fmt.Errorf("api key %s: %w", id, ErrNotFound)
+	}
+	key.Usage = maps.Clone(key.Usage)
+	if key.Usage == nil {
+		key.Usage = make(map[string]int64)
+	}
+	key.Usage[kind]++
+	key.LastUsedAt = time.Now()
+	m.apiKeys[id] = key
+	return nil
+}
+
+// RecordCollision stores c, replacing an earlier record of the same ID.
+func (m *MemoryStore) RecordCollision(ctx context.Context, c Collision) error {
+	if c.ID == "" {
+		return fmt.Errorf("collision ID is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collisions[c.ID] = c
+	return nil
+}
+
+// GetGeocode retrieves a geocoding result by key.
+func (m *MemoryStore) GetGeocode(ctx context.Context, key string) (*Geocode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, ok := m.geocodes[key]
+	if !ok {
+		return nil, fmt.Errorf("geocode %s: %w", key, ErrNotFound)
+	}
+	return &g, nil
+}
+
+// PutGeocode creates or replaces a geocoding result. Expired results are
+// only replaced, never removed.
+func (m *MemoryStore) PutGeocode(ctx context.Context, g Geocode) error {
+	if g.Key == "" {
+		return fmt.Errorf("geocode key is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.geocodes[g.Key] = g
+	return nil
+}
@@ -0,0 +1,310 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testStore checks the behavior every Store implementation shares. s must be
+// empty.
+func testStore(t *testing.T, s Store) {
+	t.Run("Locations", func(t *testing.T) { testLocations(t, s) })
+	t.Run("UpdateLocation", func(t *testing.T) { testUpdateLocation(t, s) })
+	t.Run("Jobs", func(t *testing.T) { testJobs(t, s) })
+	t.Run("Leases", func(t *testing.T) { testLeases(t, s) })
+	t.Run("Counters", func(t *testing.T) { testCounters(t, s) })
+	t.Run("APIKeys", func(t *testing.T) { testAPIKeys(t, s) })
+	t.Run("Geocodes", func(t *testing.T) { testGeocodes(t, s) })
+}
+
+func ids(locations []Location) []string {
+	var ids []string
+	for _, loc := range locations {
+		ids = append(ids, loc.ID)
+	}
+	return ids
+}
+
+func testLocations(t *testing.T, s Store) {
+	ctx := context.Background()
+	started := time.Now().Add(-time.Minute)
+	for _, loc := range []Location{
+		{ID: "tokyo", Name: "Tokyo", IsPreset: true, VideoOpName: "op-tokyo", VideoOpStartedAt: &started},
+		{ID: "paris", Name: "Paris", IsPreset: true},
+		// A user search with the same ID as a preset lives apart from it.
+		{ID: "paris", Name: "Paris, TX", PlaceID: "place-paris-tx", Lat: 33.66, Lng: -95.55},
+		{ID: "lyon", Name: "Lyon", PlaceID: "place-lyon", Lat: 45.76, Lng: 4.84, VideoOpName: "op-lyon"},
+		{ID: "nowhere", Name: "Nowhere"},
+	} {
+		if err := s.UpsertLocation(ctx, loc); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond) // Distinct LastUpdated
+	}
+	if err := s.UpsertLocation(ctx, Location{Name: "No ID"}); err == nil {
+		t.Error("UpsertLocation without an ID succeeded")
+	}
+
+	preset, err := s.GetLocation(ctx, PresetNamespace, "paris")
+	if err != nil || preset.Name != "Paris" || preset.LastUpdated.IsZero() {
+		t.Errorf("GetLocation(preset paris) = %+v, %v", preset, err)
+	}
+	user, err := s.GetLocation(ctx, UserNamespace, "paris")
+	if err != nil || user.Name != "Paris, TX" || user.Cell != Cell(33.66, -95.55) {
+		t.Errorf("GetLocation(user paris) = %+v, %v", user, err)
+	}
+	if _, err := s.GetLocation(ctx, UserNamespace, "tokyo"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLocation(user tokyo): %v, want ErrNotFound", err)
+	}
+
+	presets, err := s.GetPresets(ctx)
+	if got := ids(presets); err != nil || !slices.Equal(got, []string{"paris", "tokyo"}) {
+		t.Errorf("GetPresets = %q, %v", got, err)
+	}
+	all, err := s.ListLocations(ctx)
+	if got := ids(all); err != nil || !slices.Equal(got, []string{"paris", "tokyo", "lyon", "nowhere", "paris"}) {
+		t.Errorf("ListLocations = %q, %v", got, err)
+	}
+	recent, err := s.ListRecentLocations(ctx, 2)
+	if got := ids(recent); err != nil || !slices.Equal(got, []string{"nowhere", "lyon"}) {
+		t.Errorf("ListRecentLocations(2) = %q, %v", got, err)
+	}
+	pending, err := s.GetPendingVideos(ctx)
+	if got := ids(pending); err != nil || !slices.Equal(got, []string{"tokyo", "lyon"}) {
+		t.Errorf("GetPendingVideos = %q, %v", got, err)
+	}
+	if !pending[0].VideoOpStartedAt.Equal(started) {
+		t.Errorf("pending video started at %v, want %v", pending[0].VideoOpStartedAt, started)
+	}
+
+	if loc, err := s.FindLocationByPlaceID(ctx, "place-lyon"); err != nil || loc.ID != "lyon" {
+		t.Errorf("FindLocationByPlaceID(place-lyon) = %+v, %v", loc, err)
+	}
+	if _, err := s.FindLocationByPlaceID(ctx, "place-rome"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindLocationByPlaceID(place-rome): %v, want ErrNotFound", err)
+	}
+	near, err := s.ListLocationsInCells(ctx, CellsAround(45.75, 4.85, 10))
+	if got := ids(near); err != nil || !slices.Equal(got, []string{"lyon"}) {
+		t.Errorf("ListLocationsInCells(near Lyon) = %q, %v", got, err)
+	}
+
+	// Moving keeps LastUpdated.
+	lyon, _ := s.GetLocation(ctx, UserNamespace, "lyon")
+	moved := *lyon
+	moved.ID = "lyon-fr"
+	if err := s.MoveLocation(ctx, UserNamespace, "lyon", moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetLocation(ctx, UserNamespace, "lyon"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("old location after MoveLocation: %v, want ErrNotFound", err)
+	}
+	if got, err := s.GetLocation(ctx, UserNamespace, "lyon-fr"); err != nil || !got.LastUpdated.Equal(lyon.LastUpdated) {
+		t.Errorf("moved location = %+v, %v; want LastUpdated %v", got, err, lyon.LastUpdated)
+	}
+	if err := s.MoveLocation(ctx, PresetNamespace, "lyon-fr", moved); err == nil {
+		t.Error("MoveLocation into the wrong namespace succeeded")
+	}
+
+	if err := s.DeleteLocation(ctx, UserNamespace, "paris"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetLocation(ctx, PresetNamespace, "paris"); err != nil {
+		t.Errorf("deleting the user paris deleted the preset: %v", err)
+	}
+	if err := s.DeleteLocation(ctx, UserNamespace, "paris"); err != nil {
+		t.Errorf("deleting a missing location: %v", err)
+	}
+}
+
+func testUpdateLocation(t *testing.T, s Store) {
+	ctx := context.Background()
+	s.UpsertLocation(ctx, Location{ID: "oslo", Name: "Oslo", ImageURL: "old.png", VideoURL: "old.mp4"})
+	before, _ := s.GetLocation(ctx, UserNamespace, "oslo")
+
+	err := s.UpdateLocation(ctx, UserNamespace, "oslo", func(loc *Location) bool {
+		loc.ImageURL = "new.png"
+		loc.Lat, loc.Lng = 59.91, 10.75
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.GetLocation(ctx, UserNamespace, "oslo")
+	if got.ImageURL != "new.png" || got.VideoURL != "old.mp4" || got.Name != "Oslo" || got.Cell != Cell(59.91, 10.75) {
+		t.Errorf("after UpdateLocation: %+v, want only the image and coordinates changed", got)
+	}
+	if !got.LastUpdated.Equal(before.LastUpdated) {
+		t.Errorf("UpdateLocation changed LastUpdated from %v to %v", before.LastUpdated, got.LastUpdated)
+	}
+
+	// Returning false discards the changes.
+	s.UpdateLocation(ctx, UserNamespace, "oslo", func(loc *Location) bool {
+		loc.Name = "Christiania"
+		return false
+	})
+	if got, _ := s.GetLocation(ctx, UserNamespace, "oslo"); got.Name != "Oslo" {
+		t.Errorf("discarded update saved name %q", got.Name)
+	}
+
+	if err := s.UpdateLocation(ctx, PresetNamespace, "oslo", func(*Location) bool { return true }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateLocation of a missing location: %v, want ErrNotFound", err)
+	}
+}
+
+func testJobs(t *testing.T, s Store) {
+	ctx := context.Background()
+	now := time.Now()
+	job := Job{
+		ID: "job1", City: "Paris", Stage: JobQueued,
+		Events:    []JobEvent{{ID: 1, Event: "job", Data: "job1"}},
+		CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour),
+	}
+	if err := s.UpsertJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	// The stored job doesn't share the caller's events.
+	job.Events[0].Data = "changed"
+	job.Events = append(job.Events, JobEvent{ID: 2, Event: "done", Data: "job1"})
+	got, err := s.GetJob(ctx, "job1")
+	if err != nil || len(got.Events) != 1 || got.Events[0].Data != "job1" || got.City != "Paris" {
+		t.Errorf("GetJob = %+v, %v", got, err)
+	}
+
+	s.UpsertJob(ctx, Job{ID: "old", ExpiresAt: now.Add(-time.Second)})
+	if _, err := s.GetJob(ctx, "old"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetJob of an expired job: %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetJob(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetJob of a missing job: %v, want ErrNotFound", err)
+	}
+	if err := s.UpsertJob(ctx, Job{}); err == nil {
+		t.Error("UpsertJob without an ID succeeded")
+	}
+}
+
+func testLeases(t *testing.T, s Store) {
+	ctx := context.Background()
+	acquire := func(holder string, ttl time.Duration) string {
+		t.Helper()
+		got, err := s.AcquireLease(ctx, "paris", holder, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if got := acquire("a", time.Minute); got != "a" {
+		t.Errorf("first holder got %s", got)
+	}
+	if got := acquire("b", time.Minute); got != "a" {
+		t.Errorf("second holder got %s, want a", got)
+	}
+	if got := acquire("a", time.Minute); got != "a" {
+		t.Errorf("renewal got %s", got)
+	}
+	// Only the holder can release.
+	s.ReleaseLease(ctx, "paris", "b")
+	if got := acquire("b", time.Minute); got != "a" {
+		t.Errorf("after release by another: %s, want a", got)
+	}
+	if err := s.ReleaseLease(ctx, "paris", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := acquire("b", 20*time.Millisecond); got != "b" {
+		t.Errorf("after release: %s, want b", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := acquire("a", time.Minute); got != "a" {
+		t.Errorf("after expiry: %s, want a", got)
+	}
+	if err := s.ReleaseLease(ctx, "rome", "a"); err != nil {
+		t.Errorf("releasing a missing lease: %v", err)
+	}
+}
+
+func testCounters(t *testing.T, s Store) {
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		c, ok, err := s.IncrementCounter(ctx, "image_ip:1", 2, 50*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := i <= 2; ok != want || c.Count != min(i, 2) {
+			t.Errorf("increment %d = %+v, %v; want count %d, %v", i, c, ok, min(i, 2), want)
+		}
+	}
+	if _, ok, _ := s.IncrementCounter(ctx, "image_ip:2", 2, time.Minute); !ok {
+		t.Error("counters are not per key")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if c, ok, _ := s.IncrementCounter(ctx, "image_ip:1", 2, time.Minute); !ok || c.Count != 1 {
+		t.Errorf("after the window: %+v, %v; want a new window", c, ok)
+	}
+}
+
+func testAPIKeys(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.UpsertAPIKey(ctx, APIKey{ID: "hash1", Name: "partner"}); err != nil {
+		t.Fatal(err)
+	}
+	s.UpsertAPIKey(ctx, APIKey{ID: "hash2", Name: "ops", Admin: true})
+	if err := s.UpsertAPIKey(ctx, APIKey{Name: "no ID"}); err == nil {
+		t.Error("UpsertAPIKey without an ID succeeded")
+	}
+
+	for range 2 {
+		if err := s.RecordUsage(ctx, "hash1", "image"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.RecordUsage(ctx, "hash1", "video")
+	key, err := s.GetAPIKey(ctx, "hash1")
+	if err != nil || key.Usage["image"] != 2 || key.Usage["video"] != 1 || key.LastUsedAt.IsZero() {
+		t.Errorf("GetAPIKey after usage = %+v, %v", key, err)
+	}
+	if err := s.RecordUsage(ctx, "missing", "image"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RecordUsage of a missing key: %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetAPIKey(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAPIKey of a missing key: %v, want ErrNotFound", err)
+	}
+
+	keys, err := s.ListAPIKeys(ctx)
+	if err != nil || len(keys) != 2 {
+		t.Errorf("ListAPIKeys = %+v, %v", keys, err)
+	}
+}
+
+func testGeocodes(t *testing.T, s Store) {
+	ctx := context.Background()
+	g := Geocode{
+		Key:       "q_paris",
+		Places:    []GeocodedPlace{{PlaceID: "place-paris", Name: "Paris, France", Country: "FR", Lat: 48.85, Lng: 2.35}},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := s.PutGeocode(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	s.PutGeocode(ctx, Geocode{Key: "q_atlantis", NotFound: true, ExpiresAt: time.Now().Add(time.Hour)})
+
+	got, err := s.GetGeocode(ctx, "q_paris")
+	if err != nil || len(got.Places) != 1 || got.Places[0] != g.Places[0] || !got.ExpiresAt.Equal(g.ExpiresAt) {
+		t.Errorf("GetGeocode(q_paris) = %+v, %v; want %+v", got, err, g)
+	}
+	if got, err := s.GetGeocode(ctx, "q_atlantis"); err != nil || !got.NotFound {
+		t.Errorf("GetGeocode(q_atlantis) = %+v, %v; want not found", got, err)
+	}
+	if _, err := s.GetGeocode(ctx, "q_rome"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetGeocode of a missing key: %v, want ErrNotFound", err)
+	}
+	if err := s.PutGeocode(ctx, Geocode{}); err == nil {
+		t.Error("PutGeocode without a key succeeded")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
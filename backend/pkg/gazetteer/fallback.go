@@ -0,0 +1,69 @@
+package gazetteer
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+
+	"banana-weather/pkg/maps"
+)
+
+// fallbacks counts the lookups Fallback answered offline, published with
+// expvar (served at /api/admin/vars).
+var fallbacks = expvar.NewInt("geocoder_fallbacks")
+
+// Fallback is a maps.Geocoder that asks another one, usually the Maps API,
+// and the gazetteer when that fails. "Not found" is an answer, not a
+// failure: the gazetteer knows fewer places.
+type Fallback struct {
+	primary maps.Geocoder
+	offline *Service
+}
+
+// NewFallback returns a Fallback from primary to offline.
+func NewFallback(primary maps.Geocoder, offline *Service) *Fallback {
+	return &Fallback{primary: primary, offline: offline}
+}
+
+func (f *Fallback) FindCities(ctx context.Context, query string) ([]maps.Place, error) {
+	places, err := f.primary.FindCities(ctx, query)
+	if !f.failed(err) {
+		return places, err
+	}
+	if places, offlineErr := f.offline.FindCities(ctx, query); offlineErr == nil {
+		return places, nil
+	}
+	return nil, err
+}
+
+// GetPlace looks up gazetteer place IDs, e.g. of candidates FindCities
+// found offline, in the gazetteer and any others with primary.
+func (f *Fallback) GetPlace(ctx context.Context, placeID string) (maps.Place, error) {
+	if IsPlaceID(placeID) {
+		return f.offline.GetPlace(ctx, placeID)
+	}
+	return f.primary.GetPlace(ctx, placeID)
+}
+
+func (f *Fallback) GetReverseGeocoding(ctx context.Context, lat, lng float64) (maps.Place, error) {
+	place, err := f.primary.GetReverseGeocoding(ctx, lat, lng)
+	if !f.failed(err) {
+		return place, err
+	}
+	if place, offlineErr := f.offline.GetReverseGeocoding(ctx, lat, lng); offlineErr == nil {
+		return place, nil
+	}
+	return maps.Place{}, err
+}
+
+// failed reports whether err from primary calls for the gazetteer, and
+// counts it.
+func (f *Fallback) failed(err error) bool {
+	if err == nil || errors.Is(err, maps.ErrNotFound) {
+		return false
+	}
+	log.Printf("Geocoder failed, falling back to the gazetteer: %v", err)
+	fallbacks.Add(1)
+	return true
+}
@@ -0,0 +1,355 @@
+package gazetteer
+
+import (
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	_ "time/tzdata" // TimezoneAt's zones must load on images without zoneinfo
+
+	"banana-weather/pkg/maps"
+)
+
+//go:embed cities.csv
+var citiesCSV string
+
+// City is a single gazetteer entry.
+type City struct {
+	GeoNameID      int // 0 for the embedded list
+	Name           string
+	AlternateNames []string // Other names it is found by, e.g. "Munich" for München
+	Admin          string   // State/province short code, if any (e.g. CA)
+	Country        string
+	CountryCode    string
+	Lat            float64
+	Lng            float64
+	Population     int    // 0 if unknown
+	Timezone       string // IANA zone name
+}
+
+// FormattedAddress mirrors the Maps API style, e.g. "San Francisco, CA, United States".
+func (c City) FormattedAddress() string {
+	if c.Admin != "" {
+		return fmt.Sprintf("%s, %s, %s", c.Name, c.Admin, c.Country)
+	}
+	return fmt.Sprintf("%s, %s", c.Name, c.Country)
+}
+
+// FriendlyName mirrors the reverse geocoding style of maps.Service, e.g. "Denver, CO".
+func (c City) FriendlyName() string {
+	if c.Admin != "" {
+		return c.Name + ", " + c.Admin
+	}
+	return c.Name + ", " + c.CountryCode
+}
+
+// PlaceID identifies the city like a Maps place ID, e.g.
+// "gazetteer:US:CO:Denver", or "gazetteer:geonames:5419384" for a city
+// loaded from GeoNames. It never matches a real one.
+func (c City) PlaceID() string {
+	if c.GeoNameID != 0 {
+		return fmt.Sprintf("%sgeonames:%d", placeIDPrefix, c.GeoNameID)
+	}
+	return fmt.Sprintf("%s%s:%s:%s", placeIDPrefix, c.CountryCode, c.Admin, c.Name)
+}
+
+// placeIDPrefix starts every gazetteer place ID.
+const placeIDPrefix = "gazetteer:"
+
+// IsPlaceID reports whether id is a gazetteer place ID rather than a Maps one.
+func IsPlaceID(id string) bool {
+	return strings.HasPrefix(id, placeIDPrefix)
+}
+
+// place returns the city as a maps.Place called name.
+func (c City) place(name string) maps.Place {
+	return maps.Place{ID: c.PlaceID(), Name: name, Country: c.Country, Lat: c.Lat, Lng: c.Lng}
+}
+
+// Service is an offline geocoder backed by a list of world cities: a small
+// embedded one, or a GeoNames dump (see LoadGeoNames). It satisfies
+// maps.Geocoder and is used when running without Google credentials.
+type Service struct {
+	cities []City // Most populous first
+
+	byName map[string][]int // Normalized names and alternate names to cities
+	names  []string         // Keys of byName, sorted for prefix searches
+	byID   map[string]int   // Place IDs to cities
+}
+
+// embeddedCities parses the embedded list once for NewService and TimezoneAt.
+var embeddedCities = sync.OnceValues(func() ([]City, error) {
+	return parseCities(citiesCSV)
+})
+
+// NewService returns a gazetteer of the embedded list of cities.
+func NewService() (*Service, error) {
+	cities, err := embeddedCities()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Gazetteer loaded with %d cities", len(cities))
+	return newService(cities), nil
+}
+
+// NewServiceFromEnv returns a gazetteer of the GeoNames dump at
+// GAZETTEER_FILE (e.g. cities15000.zip), or of the embedded list if it is
+// not set.
+func NewServiceFromEnv() (*Service, error) {
+	path := os.Getenv("GAZETTEER_FILE")
+	if path == "" {
+		return NewService()
+	}
+	cities, err := LoadGeoNames(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Gazetteer loaded with %d cities from %s", len(cities), path)
+	return newService(cities), nil
+}
+
+// newService indexes cities by name, alternate names and place ID.
+func newService(cities []City) *Service {
+	cities = slices.Clone(cities)
+	sort.SliceStable(cities, func(i, j int) bool { return cities[i].Population > cities[j].Population })
+
+	s := &Service{
+		cities: cities,
+		byName: make(map[string][]int),
+		byID:   make(map[string]int, len(cities)),
+	}
+	for i, c := range cities {
+		s.byID[c.PlaceID()] = i
+		for _, name := range append([]string{c.Name}, c.AlternateNames...) {
+			key := normalize(name)
+			if key == "" {
+				continue
+			}
+			ids := s.byName[key]
+			if len(ids) == 0 {
+				s.names = append(s.names, key)
+			}
+			if len(ids) == 0 || ids[len(ids)-1] != i {
+				s.byName[key] = append(ids, i)
+			}
+		}
+	}
+	sort.Strings(s.names)
+	return s
+}
+
+func parseCities(data string) ([]City, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gazetteer: %w", err)
+	}
+
+	var cities []City
+	for i, row := range records {
+		if i == 0 {
+			continue // Skip Header
+		}
+		if len(row) < 8 {
+			return nil, fmt.Errorf("gazetteer line %d: expected 8 fields, got %d", i+1, len(row))
+		}
+		lat, err := strconv.ParseFloat(row[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: bad latitude: %w", i+1, err)
+		}
+		lng, err := strconv.ParseFloat(row[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: bad longitude: %w", i+1, err)
+		}
+		population, err := strconv.Atoi(row[7])
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: bad population: %w", i+1, err)
+		}
+		cities = append(cities, City{
+			Name:        row[0],
+			Admin:       row[1],
+			Country:     row[2],
+			CountryCode: row[3],
+			Lat:         lat,
+			Lng:         lng,
+			Timezone:    row[6],
+			Population:  population,
+		})
+	}
+	return cities, nil
+}
+
+// Cities returns all entries in the gazetteer.
+func (s *Service) Cities() []City {
+	return s.cities
+}
+
+// Search finds the cities matching a free-text query such as "paris" or
+// "Sydney, Australia", most populous first: those named exactly that
+// (alternate names included), or else those whose name starts with it.
+// Anything after the first comma is used as a hint matched against the admin
+// code, country name or country code.
+func (s *Service) Search(query string) []City {
+	parts := strings.Split(query, ",")
+	name := normalize(parts[0])
+	if name == "" {
+		return nil
+	}
+	var hints []string
+	for _, p := range parts[1:] {
+		if h := normalize(p); h != "" {
+			hints = append(hints, h)
+		}
+	}
+
+	if exact := s.matching(s.byName[name], hints); len(exact) > 0 {
+		return exact
+	}
+	var ids []int
+	for i := sort.SearchStrings(s.names, name); i < len(s.names) && strings.HasPrefix(s.names[i], name); i++ {
+		ids = append(ids, s.byName[s.names[i]]...)
+	}
+	slices.Sort(ids) // Most populous first, as in s.cities
+	return s.matching(slices.Compact(ids), hints)
+}
+
+// matching returns the cities of ids that match every hint.
+func (s *Service) matching(ids []int, hints []string) []City {
+	var cities []City
+	for _, i := range ids {
+		if matchesHints(s.cities[i], hints) {
+			cities = append(cities, s.cities[i])
+		}
+	}
+	return cities
+}
+
+// Nearest returns the city closest to the given coordinates.
+func (s *Service) Nearest(lat, lng float64) (City, float64) {
+	best, bestDist := City{}, math.Inf(1)
+	for _, c := range s.cities {
+		if d := Distance(lat, lng, c.Lat, c.Lng); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best, bestDist
+}
+
+const (
+	// maxCandidates caps how many cities FindCities returns, e.g. for "san".
+	maxCandidates = 10
+	// minPopulationShare is how populous a city must be, relative to the
+	// most populous match, to be offered as a candidate: "Paris" is Paris,
+	// France rather than Paris, Texas, but "Springfield" is ambiguous.
+	minPopulationShare = 0.1
+)
+
+// FindCities returns the cities Search finds for query that are plausibly
+// meant by it.
+func (s *Service) FindCities(ctx context.Context, query string) ([]maps.Place, error) {
+	log.Printf("Gazetteer geocoding city: %s", query)
+	cities := s.Search(query)
+	if len(cities) == 0 {
+		log.Printf("Gazetteer found no results for: %s", query)
+		return nil, fmt.Errorf("city %w", maps.ErrNotFound)
+	}
+	threshold := int(float64(cities[0].Population) * minPopulationShare)
+	cities = slices.DeleteFunc(cities, func(c City) bool { return c.Population < threshold })
+	places := make([]maps.Place, min(len(cities), maxCandidates))
+	for i, c := range cities[:len(places)] {
+		places[i] = c.place(c.FormattedAddress())
+	}
+	return places, nil
+}
+
+// GetPlace looks up a city by the ID City.PlaceID gives it.
+func (s *Service) GetPlace(ctx context.Context, placeID string) (maps.Place, error) {
+	i, ok := s.byID[placeID]
+	if !ok {
+		return maps.Place{}, fmt.Errorf("place %w", maps.ErrNotFound)
+	}
+	c := s.cities[i]
+	return c.place(c.FormattedAddress()), nil
+}
+
+// Autocomplete suggests the cities Search finds for input.
+func (s *Service) Autocomplete(ctx context.Context, input string) ([]maps.Suggestion, error) {
+	cities := s.Search(input)
+	suggestions := make([]maps.Suggestion, min(len(cities), maxCandidates))
+	for i, c := range cities[:len(suggestions)] {
+		suggestions[i] = maps.Suggestion{Text: c.FormattedAddress(), PlaceID: c.PlaceID()}
+	}
+	return suggestions, nil
+}
+
+func (s *Service) GetReverseGeocoding(ctx context.Context, lat, lng float64) (maps.Place, error) {
+	log.Printf("Gazetteer reverse geocoding lat: %f, lng: %f", lat, lng)
+	if len(s.cities) == 0 {
+		return maps.Place{}, fmt.Errorf("location %w", maps.ErrNotFound)
+	}
+	c, dist := s.Nearest(lat, lng)
+	log.Printf("Gazetteer nearest city: %s (%.0f km)", c.Name, dist)
+	return c.place(c.FriendlyName()), nil
+}
+
+// maxTimezoneDistanceKm is how far from the nearest city TimezoneAt still
+// trusts that city's zone. With a full GeoNames dump most places have a city
+// much closer; with the embedded list most fall back to the nautical zone.
+const maxTimezoneDistanceKm = 150
+
+// TimezoneAt estimates the IANA timezone at a coordinate without network
+// access: the zone of the nearest city within 150 km, otherwise the nautical
+// zone (see NauticalZone). Near zone borders it can be an hour or so off. A
+// nil Service always gives the nautical zone.
+func (s *Service) TimezoneAt(lat, lng float64) string {
+	if s != nil {
+		if c, dist := s.Nearest(lat, lng); dist <= maxTimezoneDistanceKm && c.Timezone != "" {
+			return c.Timezone
+		}
+	}
+	return NauticalZone(lng)
+}
+
+// NauticalZone returns the fixed-offset zone for a longitude, e.g.
+// "Etc/GMT-9" for 135°E.
+func NauticalZone(lng float64) string {
+	offset := int(math.Round(lng / 15))
+	offset = max(-12, min(offset, 12))
+	if offset == 0 {
+		return "Etc/GMT"
+	}
+	// Etc zone names have the POSIX sign: Etc/GMT-9 is nine hours ahead of UTC.
+	return fmt.Sprintf("Etc/GMT%+d", -offset)
+}
+
+// Distance returns the great-circle distance in kilometers between two points.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(d float64) float64 { return d * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func matchesHints(c City, hints []string) bool {
+	for _, h := range hints {
+		if h != normalize(c.Admin) && h != normalize(c.Country) && h != normalize(c.CountryCode) &&
+			!(h == "usa" && c.CountryCode == "US") && !(h == "uk" && c.CountryCode == "GB") {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,283 @@
+package gazetteer
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"banana-weather/pkg/maps"
+)
+
+// geoNamesRow returns a line of a GeoNames dump.
+func geoNamesRow(id, name, alternates, lat, lng, country, admin, population, timezone string) string {
+	row := make([]string, geoNamesFields)
+	row[0], row[1], row[2], row[3] = id, name, name, alternates
+	row[4], row[5], row[8], row[10] = lat, lng, country, admin
+	row[14], row[17] = population, timezone
+	return strings.Join(row, "\t")
+}
+
+var testDump = strings.Join([]string{
+	geoNamesRow("2988507", "Paris", "Lutece,Paname", "48.85341", "2.3488", "FR", "11", "2138551", "Europe/Paris"),
+	geoNamesRow("4717560", "Paris", "", "33.66094", "-95.55551", "US", "TX", "24171", "America/Chicago"),
+	geoNamesRow("4250542", "Springfield", "", "39.80172", "-89.64371", "US", "IL", "114394", "America/Chicago"),
+	geoNamesRow("4409896", "Springfield", "", "37.21533", "-93.29824", "US", "MO", "169176", "America/Chicago"),
+	geoNamesRow("4951788", "Springfield", "", "42.10148", "-72.58981", "US", "MA", "155929", "America/New_York"),
+	geoNamesRow("2867714", "München", "Munich,Monaco di Baviera", "48.13743", "11.57549", "DE", "02", "1260391", "Europe/Berlin"),
+	geoNamesRow("2867993", "Münster", "", "51.96236", "7.62571", "DE", "07", "270184", "Europe/Berlin"),
+	geoNamesRow("9999999", "Smallville", "", "39.0", "-98.0", "US", "KS", "", "America/Chicago"),
+}, "\n") + "\n"
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	cities, err := parseGeoNames(strings.NewReader(testDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newService(cities)
+}
+
+func names(places []maps.Place) []string {
+	var names []string
+	for _, p := range places {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestFindCities(t *testing.T) {
+	s := newTestService(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Much more populous than any other Paris.
+		{"Paris", []string{"Paris, France"}},
+		{"  paris ", []string{"Paris, France"}},
+		{"Paris, US", []string{"Paris, TX, United States"}},
+		{"Paris, TX", []string{"Paris, TX, United States"}},
+		{"Lutece", []string{"Paris, France"}},
+		// Comparable populations, most populous first.
+		{"Springfield", []string{"Springfield, MO, United States", "Springfield, MA, United States", "Springfield, IL, United States"}},
+		{"Springfield, MA", []string{"Springfield, MA, United States"}},
+		{"Munich", []string{"München, Germany"}},
+		// Prefixes of several names.
+		{"Mün", []string{"München, Germany", "Münster, Germany"}},
+		{"Smallville", []string{"Smallville, KS, United States"}},
+	}
+	for _, tt := range tests {
+		places, err := s.FindCities(context.Background(), tt.query)
+		if err != nil {
+			t.Errorf("FindCities(%q): %v", tt.query, err)
+			continue
+		}
+		if got := names(places); !slices.Equal(got, tt.want) {
+			t.Errorf("FindCities(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"Atlantis", "Paris, Germany", "", " , "} {
+		if _, err := s.FindCities(context.Background(), query); !errors.Is(err, maps.ErrNotFound) {
+			t.Errorf("FindCities(%q): %v, want ErrNotFound", query, err)
+		}
+	}
+}
+
+func TestFindCitiesEmbedded(t *testing.T) {
+	s, err := NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range s.Cities() {
+		if c.Population == 0 || c.Timezone == "" {
+			t.Errorf("embedded city %s lacks a population or timezone", c.Name)
+		}
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Paris", []string{"Paris, France"}},
+		{"San Francisco", []string{"San Francisco, CA, United States"}},
+		{"San", []string{"Santiago, Chile", "San Francisco, CA, United States"}},
+		{"Ma", []string{"Madrid, Spain", "Manila, Philippines", "Marrakesh, Morocco"}},
+		// Munich is less than a tenth of Mumbai.
+		{"Mu", []string{"Mumbai, MH, India"}},
+	}
+	for _, tt := range tests {
+		places, err := s.FindCities(context.Background(), tt.query)
+		if err != nil {
+			t.Errorf("FindCities(%q): %v", tt.query, err)
+			continue
+		}
+		if got := names(places); !slices.Equal(got, tt.want) {
+			t.Errorf("FindCities(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestGetPlace(t *testing.T) {
+	s := newTestService(t)
+	places, err := s.FindCities(context.Background(), "Springfield")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range places {
+		if !IsPlaceID(want.ID) {
+			t.Errorf("place ID %q is not a gazetteer one", want.ID)
+		}
+		got, err := s.GetPlace(context.Background(), want.ID)
+		if err != nil || got != want {
+			t.Errorf("GetPlace(%q) = %+v, %v; want %+v", want.ID, got, err, want)
+		}
+	}
+	if _, err := s.GetPlace(context.Background(), "gazetteer:geonames:1"); !errors.Is(err, maps.ErrNotFound) {
+		t.Errorf("GetPlace of an unknown ID: %v, want ErrNotFound", err)
+	}
+}
+
+func TestTimezoneAt(t *testing.T) {
+	s := newTestService(t)
+	var none *Service
+	tests := []struct {
+		s        *Service
+		lat, lng float64
+		want     string
+	}{
+		{s, 48.86, 2.35, "Europe/Paris"},
+		{s, 48.0, 11.0, "Europe/Berlin"}, // Near Munich
+		{s, 37.77, -122.42, "Etc/GMT+8"}, // No city within range
+		{s, 0, -150, "Etc/GMT+10"},
+		{none, 48.86, 2.35, "Etc/GMT"},
+	}
+	for _, tt := range tests {
+		if got := tt.s.TimezoneAt(tt.lat, tt.lng); got != tt.want {
+			t.Errorf("TimezoneAt(%v, %v) = %q, want %q", tt.lat, tt.lng, got, tt.want)
+		}
+	}
+}
+
+func TestNauticalZone(t *testing.T) {
+	tests := []struct {
+		lng  float64
+		want string
+	}{
+		{0, "Etc/GMT"},
+		{7.4, "Etc/GMT"},
+		{7.6, "Etc/GMT-1"},
+		{135, "Etc/GMT-9"},
+		{-75, "Etc/GMT+5"},
+		{180, "Etc/GMT-12"},
+		{-180, "Etc/GMT+12"},
+	}
+	for _, tt := range tests {
+		if got := NauticalZone(tt.lng); got != tt.want {
+			t.Errorf("NauticalZone(%v) = %q, want %q", tt.lng, got, tt.want)
+		}
+	}
+}
+
+func TestLoadGeoNames(t *testing.T) {
+	dir := t.TempDir()
+	txt := filepath.Join(dir, "cities.txt")
+	if err := os.WriteFile(txt, []byte(testDump), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "cities.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("cities15000.txt")
+	w.Write([]byte(testDump))
+	zw.Close()
+	f.Close()
+
+	for _, path := range []string{txt, archive} {
+		cities, err := LoadGeoNames(path)
+		if err != nil {
+			t.Fatalf("LoadGeoNames(%s): %v", path, err)
+		}
+		if len(cities) != 8 {
+			t.Fatalf("LoadGeoNames(%s): %d cities, want 8", path, len(cities))
+		}
+		if c := cities[0]; c.GeoNameID != 2988507 || c.Country != "France" || c.Admin != "" || c.Population != 2138551 || c.Timezone != "Europe/Paris" {
+			t.Errorf("LoadGeoNames(%s)[0] = %+v, want Paris, France", path, c)
+		}
+	}
+
+	if _, err := parseGeoNames(strings.NewReader("1\tParis\n")); err == nil {
+		t.Error("parseGeoNames accepted a short line")
+	}
+}
+
+func TestParseCities(t *testing.T) {
+	const header = "name,admin,country,country_code,lat,lng,timezone,population\n"
+	cities, err := parseCities(header + "Denver,CO,United States,US,39.7392,-104.9903,America/Denver,715522\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := City{Name: "Denver", Admin: "CO", Country: "United States", CountryCode: "US", Lat: 39.7392, Lng: -104.9903, Population: 715522, Timezone: "America/Denver"}
+	if !reflect.DeepEqual(cities, []City{want}) {
+		t.Errorf("parseCities = %+v, want %+v", cities, want)
+	}
+
+	for _, line := range []string{
+		"Denver,CO,United States,US,39.7392,-104.9903,America/Denver",
+		"Denver,CO,United States,US,north,-104.9903,America/Denver,715522",
+		"Denver,CO,United States,US,39.7392,-104.9903,America/Denver,many",
+	} {
+		if _, err := parseCities(header + line + "\n"); err == nil {
+			t.Errorf("parseCities accepted %q", line)
+		}
+	}
+}
+
+// failingGeocoder fails every lookup with err.
+type failingGeocoder struct{ err error }
+
+func (g failingGeocoder) FindCities(ctx context.Context, query string) ([]maps.Place, error) {
+	return nil, g.err
+}
+
+func (g failingGeocoder) GetPlace(ctx context.Context, placeID string) (maps.Place, error) {
+	return maps.Place{}, g.err
+}
+
+func (g failingGeocoder) GetReverseGeocoding(ctx context.Context, lat, lng float64) (maps.Place, error) {
+	return maps.Place{}, g.err
+}
+
+func TestFallback(t *testing.T) {
+	ctx := context.Background()
+	offline := newTestService(t)
+
+	down := NewFallback(failingGeocoder{errors.New("quota exceeded")}, offline)
+	if places, err := down.FindCities(ctx, "Paris"); err != nil || len(places) != 1 {
+		t.Errorf("FindCities with the primary down = %v, %v; want the gazetteer's Paris", places, err)
+	}
+	if place, err := down.GetReverseGeocoding(ctx, 48.86, 2.35); err != nil || place.Name != "Paris, FR" {
+		t.Errorf("GetReverseGeocoding with the primary down = %+v, %v; want Paris", place, err)
+	}
+	if _, err := down.FindCities(ctx, "Atlantis"); err == nil || errors.Is(err, maps.ErrNotFound) {
+		t.Errorf("FindCities unknown offline: %v, want the primary's error", err)
+	}
+
+	// "Not found" is the primary's answer, not a failure.
+	missing := NewFallback(failingGeocoder{maps.ErrNotFound}, offline)
+	if _, err := missing.FindCities(ctx, "Paris"); !errors.Is(err, maps.ErrNotFound) {
+		t.Errorf("FindCities not found by the primary: %v, want ErrNotFound", err)
+	}
+
+	// Gazetteer place IDs never go to the primary.
+	if place, err := missing.GetPlace(ctx, "gazetteer:geonames:4951788"); err != nil || place.Name != "Springfield, MA, United States" {
+		t.Errorf("GetPlace of a gazetteer ID = %+v, %v; want Springfield, MA", place, err)
+	}
+}
@@ -0,0 +1,116 @@
+package gazetteer
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// geoNamesFields is the number of tab-separated columns of a GeoNames dump.
+const geoNamesFields = 19
+
+// LoadGeoNames reads a GeoNames cities dump such as cities15000.txt, as
+// downloaded from https://download.geonames.org/export/dump/, or the .zip
+// archive it comes in.
+func LoadGeoNames(path string) ([]City, error) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		return loadGeoNamesZip(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gazetteer: %w", err)
+	}
+	defer f.Close()
+	return parseGeoNames(f)
+}
+
+// loadGeoNamesZip reads the first .txt file of a GeoNames archive.
+func loadGeoNamesZip(path string) ([]City, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gazetteer: %w", err)
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		if !strings.EqualFold(filepath.Ext(file.Name), ".txt") {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in gazetteer: %w", file.Name, err)
+		}
+		defer f.Close()
+		return parseGeoNames(f)
+	}
+	return nil, fmt.Errorf("gazetteer %s has no .txt file", path)
+}
+
+// parseGeoNames parses the GeoNames "geoname" table format: one place per
+// line, tab-separated.
+func parseGeoNames(r io.Reader) ([]City, error) {
+	regions := display.English.Regions()
+
+	var cities []City
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // Alternate names make long lines
+	for line := 1; scanner.Scan(); line++ {
+		row := strings.Split(scanner.Text(), "\t")
+		if len(row) < geoNamesFields {
+			return nil, fmt.Errorf("gazetteer line %d: expected %d fields, got %d", line, geoNamesFields, len(row))
+		}
+		id, err := strconv.Atoi(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: bad geonameid: %w", line, err)
+		}
+		lat, err := strconv.ParseFloat(row[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: bad latitude: %w", line, err)
+		}
+		lng, err := strconv.ParseFloat(row[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: bad longitude: %w", line, err)
+		}
+		population, _ := strconv.Atoi(row[14]) // Often empty
+
+		c := City{
+			GeoNameID:   id,
+			Name:        row[1],
+			CountryCode: row[8],
+			Country:     row[8],
+			Lat:         lat,
+			Lng:         lng,
+			Population:  population,
+			Timezone:    row[17],
+		}
+		if row[2] != "" && row[2] != row[1] {
+			c.AlternateNames = append(c.AlternateNames, row[2])
+		}
+		if row[3] != "" {
+			c.AlternateNames = append(c.AlternateNames, strings.Split(row[3], ",")...)
+		}
+		if region, err := language.ParseRegion(row[8]); err == nil {
+			if name := regions.Name(region); name != "" {
+				c.Country = name
+			}
+		}
+		// Admin codes are numeric outside the US (e.g. "11" for
+		// Île-de-France); only US states read well in a name.
+		if c.CountryCode == "US" {
+			c.Admin = row[10]
+		}
+		cities = append(cities, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gazetteer: %w", err)
+	}
+	return cities, nil
+}
@@ -0,0 +1,198 @@
+package gc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/storage"
+)
+
+// Prefixes are the object name prefixes the collector looks at: cache-miss
+// images, preset images and videos (Veo and placeholder). Anything else in
+// the bucket, such as presets.json, is never touched.
+var Prefixes = []string{"image_", "preset_", "videos/"}
+
+// DefaultGrace is how old an unreferenced object must be before it is
+// collected. It outlasts the longest a video can take to be generated and
+// saved (see maxVideoOpAge in package api), so media that is about to be
+// referenced is safe.
+const DefaultGrace = 48 * time.Hour
+
+// Action is what the collector does with unreferenced objects.
+type Action string
+
+const (
+	DryRun  Action = "dry-run" // Only report them
+	Archive Action = "archive" // Move them under storage.ArchivePrefix
+	Delete  Action = "delete"  // Delete them
+)
+
+// ParseAction parses "dry-run", "archive" or "delete".
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case DryRun, Archive, Delete:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown action %q (want dry-run, archive or delete)", s)
+}
+
+// Collector removes media that no location references any more: images of
+// regenerated locations, videos of regenerated presets and Veo output that
+// was never saved.
+type Collector struct {
+	Objects storage.ObjectStore
+	DB      database.LocationStore
+	Grace   time.Duration
+	Action  Action
+}
+
+func NewCollector(objects storage.ObjectStore, db database.LocationStore, grace time.Duration, action Action) *Collector {
+	return &Collector{Objects: objects, DB: db, Grace: grace, Action: action}
+}
+
+// NewCollectorFromEnv creates a Collector configured by GC_GRACE (default
+// 48h) and GC_ACTION (default archive).
+func NewCollectorFromEnv(objects storage.ObjectStore, db database.LocationStore) (*Collector, error) {
+	grace := DefaultGrace
+	if v := os.Getenv("GC_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid GC_GRACE %q", v)
+		}
+		grace = d
+	}
+	action := Archive
+	if v := os.Getenv("GC_ACTION"); v != "" {
+		var err error
+		if action, err = ParseAction(v); err != nil {
+			return nil, fmt.Errorf("invalid GC_ACTION: %w", err)
+		}
+	}
+	return NewCollector(objects, db, grace, action), nil
+}
+
+// Report summarizes a collection run.
+type Report struct {
+	Action     Action
+	Scanned    int              // Objects under Prefixes
+	Referenced int              // Still used by a location
+	Recent     int              // Unreferenced but within the grace period
+	Orphans    []storage.Object // Unreferenced and past the grace period
+	Bytes      int64            // Total size of Orphans
+	Removed    int              // Orphans archived or deleted
+	Failed     int              // Orphans that could not be removed
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%s: scanned %d objects, %d referenced, %d within grace period, %d orphaned (%d bytes), %d removed, %d failed",
+		r.Action, r.Scanned, r.Referenced, r.Recent, len(r.Orphans), r.Bytes, r.Removed, r.Failed)
+}
+
+// Run collects once. It refuses to run if there are no locations at all,
+// which is far more likely a misconfigured database than an empty gallery,
+// or if any location can't be read, since its media would look orphaned.
+func (c *Collector) Run(ctx context.Context) (*Report, error) {
+	locations, err := c.DB.ListLocations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+	if len(locations) == 0 {
+		return nil, errors.New("no locations found; refusing to collect")
+	}
+
+	referenced := make(map[string]bool)
+	for _, loc := range locations {
+		for _, url := range []string{loc.ImageURL, loc.VideoURL} {
+			if name, ok := c.Objects.ObjectName(url); ok {
+				referenced[name] = true
+			}
+		}
+	}
+
+	report := &Report{Action: c.Action}
+	cutoff := time.Now().Add(-c.Grace)
+	for _, prefix := range Prefixes {
+		objects, err := c.Objects.ListObjects(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			report.Scanned++
+			switch {
+			case referenced[obj.Name]:
+				report.Referenced++
+			case obj.Created.After(cutoff):
+				report.Recent++
+			default:
+				report.Orphans = append(report.Orphans, obj)
+				report.Bytes += obj.Size
+				c.remove(ctx, obj, report)
+			}
+		}
+	}
+	return report, nil
+}
+
+func (c *Collector) remove(ctx context.Context, obj storage.Object, report *Report) {
+	var err error
+	switch c.Action {
+	case Archive:
+		err = c.Objects.ArchiveObject(ctx, obj.Name)
+	case Delete:
+		err = c.Objects.DeleteObject(ctx, obj.Name)
+	default:
+		return
+	}
+	if err != nil && !storage.IsNotExist(err) {
+		log.Printf("GC: failed to %s %s: %v", c.Action, obj.Name, err)
+		report.Failed++
+		return
+	}
+	report.Removed++
+}
+
+// Schedule runs the collector every interval until ctx is done. With several
+// instances sharing leases, only one of them collects per interval.
+func (c *Collector) Schedule(ctx context.Context, interval time.Duration, leases database.LeaseStore) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	holder := "gc-" + hex.EncodeToString(b)
+
+	log.Printf("GC: collecting unreferenced media every %s (%s, grace %s)", interval, c.Action, c.Grace)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if leases != nil {
+			// The lease is never released: it expires when the next run is
+			// due, so the instance that ran last normally runs again.
+			got, err := leases.AcquireLease(ctx, "gc", holder, interval)
+			if err != nil {
+				log.Printf("GC: failed to acquire lease: %v", err)
+				continue
+			}
+			if got != holder {
+				continue
+			}
+		}
+
+		report, err := c.Run(ctx)
+		if err != nil {
+			log.Printf("GC: %v", err)
+			continue
+		}
+		log.Printf("GC: %s", report)
+	}
+}
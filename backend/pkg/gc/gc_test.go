@@ -0,0 +1,160 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/storage"
+)
+
+const baseURL = "http://media.test"
+
+// newBucket returns a LocalService holding media of various ages, a store
+// whose locations reference image_paris.png and videos/paris.mp4, and the
+// directory the media is in.
+func newBucket(t *testing.T) (*storage.LocalService, *database.MemoryStore, string) {
+	t.Helper()
+	dir := t.TempDir()
+	objects, err := storage.NewLocalService(dir, baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, age := range map[string]time.Duration{
+		"image_paris.png":      72 * time.Hour, // Referenced
+		"videos/paris.mp4":     72 * time.Hour, // Referenced
+		"image_old.png":        72 * time.Hour,
+		"preset_old.png":       72 * time.Hour,
+		"videos/abandoned.mp4": 72 * time.Hour,
+		"image_new.png":        time.Hour,      // Within the grace period
+		"presets.json":         72 * time.Hour, // Not media
+	} {
+		if _, err := objects.UploadBytes(context.Background(), []byte("data"), name, ""); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(name)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := database.NewMemoryStore()
+	db.UpsertLocation(context.Background(), database.Location{ID: "paris", IsPreset: true, ImageURL: baseURL + "/image_paris.png"})
+	db.UpsertLocation(context.Background(), database.Location{ID: "user_paris", VideoURL: baseURL + "/videos/paris.mp4"})
+	return objects, db, dir
+}
+
+// remaining lists the objects left in the bucket, archived ones included.
+func remaining(t *testing.T, objects *storage.LocalService) []string {
+	t.Helper()
+	list, err := objects.ListObjects(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, obj := range list {
+		names = append(names, obj.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		action  Action
+		removed int
+		want    []string
+	}{
+		{DryRun, 0, []string{
+			"image_new.png", "image_old.png", "image_paris.png", "preset_old.png", "presets.json",
+			"videos/abandoned.mp4", "videos/paris.mp4",
+		}},
+		{Archive, 3, []string{
+			"archive/image_old.png", "archive/preset_old.png", "archive/videos/abandoned.mp4",
+			"image_new.png", "image_paris.png", "presets.json", "videos/paris.mp4",
+		}},
+		{Delete, 3, []string{
+			"image_new.png", "image_paris.png", "presets.json", "videos/paris.mp4",
+		}},
+	}
+	for _, tt := range tests {
+		objects, db, _ := newBucket(t)
+		report, err := NewCollector(objects, db, DefaultGrace, tt.action).Run(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.action, err)
+		}
+		if report.Scanned != 6 || report.Referenced != 2 || report.Recent != 1 || len(report.Orphans) != 3 ||
+			report.Bytes != 12 || report.Removed != tt.removed || report.Failed != 0 {
+			t.Errorf("%s: report %s", tt.action, report)
+		}
+		if got := remaining(t, objects); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: left %q, want %q", tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestRunAlreadyRemoved(t *testing.T) {
+	objects, db, dir := newBucket(t)
+	c := NewCollector(objects, db, DefaultGrace, Delete)
+	// Another instance removes an orphan between listing and removal.
+	c.Objects = racingStore{objects, filepath.Join(dir, "image_old.png")}
+	report, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Removed != 3 || report.Failed != 0 {
+		t.Errorf("report %s, want 3 removed", report)
+	}
+}
+
+// racingStore deletes path behind the collector's back after listing.
+type racingStore struct {
+	*storage.LocalService
+	path string
+}
+
+func (s racingStore) ListObjects(ctx context.Context, prefix string) ([]storage.Object, error) {
+	objects, err := s.LocalService.ListObjects(ctx, prefix)
+	os.Remove(s.path)
+	return objects, err
+}
+
+// failingLocations is a LocationStore that can't list locations.
+type failingLocations struct{ database.LocationStore }
+
+func (failingLocations) ListLocations(ctx context.Context) ([]database.Location, error) {
+	return nil, errors.New("permission denied")
+}
+
+func TestRunFailsClosed(t *testing.T) {
+	for name, db := range map[string]database.LocationStore{
+		"no locations":     database.NewMemoryStore(),
+		"unreadable store": failingLocations{},
+	} {
+		objects, _, _ := newBucket(t)
+		if report, err := NewCollector(objects, db, 0, Delete).Run(context.Background()); err == nil {
+			t.Errorf("%s: collected: %s", name, report)
+		}
+		if got := remaining(t, objects); len(got) != 7 {
+			t.Errorf("%s: left %q, want every object", name, got)
+		}
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	for _, s := range []string{"dry-run", "archive", "delete"} {
+		if a, err := ParseAction(s); err != nil || string(a) != s {
+			t.Errorf("ParseAction(%q) = %q, %v", s, a, err)
+		}
+	}
+	for _, s := range []string{"", "purge", "Delete"} {
+		if _, err := ParseAction(s); err == nil {
+			t.Errorf("ParseAction(%q) succeeded", s)
+		}
+	}
+}
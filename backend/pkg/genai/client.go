@@ -7,14 +7,38 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"banana-weather/pkg/storage"
+	"banana-weather/pkg/weather"
+
 	"google.golang.org/genai"
 )
 
+// ImageGenerator renders the 9:16 weather artwork for a city and returns it
+// base64 encoded. If forecast is nil the generator is expected to find the
+// current weather itself.
+type ImageGenerator interface {
+	GenerateImage(ctx context.Context, city string, extraContext string, forecast *weather.Forecast) (string, error)
+}
+
+// VideoGenerator animates a previously uploaded image. Generation is a
+// long-running operation: StartVideo returns its name, which can be stored so
+// that AwaitVideo can pick it up again after a restart.
+type VideoGenerator interface {
+	StartVideo(ctx context.Context, inputImageURI string, prompt string) (string, error)
+	AwaitVideo(ctx context.Context, opName string) (string, error)
+}
+
+// VideoPrompt is the prompt every weather image is animated with.
+const VideoPrompt = "The camera moves in parallax as the elements in the image move naturally, while the forecast data—the bold title remain fixed."
+
+// Service implements both ImageGenerator and VideoGenerator on Vertex AI.
 type Service struct {
 	client     *genai.Client
 	bucketName string
+	videoStore storage.BlobStore
 }
 
 func NewService(ctx context.Context) (*Service, error) {
@@ -26,9 +50,10 @@ func NewService(ctx context.Context) (*Service, error) {
 		return nil, fmt.Errorf("PROJECT_ID or GOOGLE_CLOUD_PROJECT not set")
 	}
 
+	// Without a bucket Veo returns the video inline; see SetVideoStore.
 	bucketName := os.Getenv("GENMEDIA_BUCKET")
 	if bucketName == "" {
-		return nil, fmt.Errorf("GENMEDIA_BUCKET not set")
+		log.Printf("Warning: GENMEDIA_BUCKET not set, Veo output will be returned inline")
 	}
 
 	location := os.Getenv("GOOGLE_CLOUD_LOCATION")
@@ -51,8 +76,14 @@ func NewService(ctx context.Context) (*Service, error) {
 	return &Service{client: c, bucketName: bucketName}, nil
 }
 
+// SetVideoStore sets where videos are written when Veo returns them inline
+// (i.e. when no GENMEDIA_BUCKET is configured).
+func (s *Service) SetVideoStore(store storage.BlobStore) {
+	s.videoStore = store
+}
+
 // GenerateImage generates a 9:16 image for the given city.
-func (s *Service) GenerateImage(ctx context.Context, city string, extraContext string) (string, error) {
+func (s *Service) GenerateImage(ctx context.Context, city string, extraContext string, forecast *weather.Forecast) (string, error) {
 	basePrompt := `Present a clear, 45° top-down view of a vertical (9:16) isometric miniature 3D cartoon scene, highlighting iconic landmarks centered in the composition to showcase precise and delicate modeling.
 
 The scene features soft, refined textures with realistic PBR materials and gentle, lifelike lighting and shadow effects. Weather elements are creatively integrated into the urban architecture, establishing a dynamic interaction between the city's landscape and atmospheric conditions, creating an immersive weather ambiance.
@@ -61,8 +92,21 @@ Use a clean, unified composition with minimalistic aesthetics and a soft, solid-
 
 Display a prominent weather icon at the top-center, with the date (x-small text) and temperature range (medium text) beneath it. The city name (large text) is positioned directly above the weather icon. The weather information has no background and can subtly overlap with the buildings.
 
-The text should match the input city's native language.
-Please retrieve current weather conditions for the specified city before rendering.`
+The text should match the input city's native language.`
+
+	// With a known forecast the numbers come from us; otherwise let the model
+	// search for current conditions.
+	config := &genai.GenerateContentConfig{
+		ResponseModalities: []string{"IMAGE"},
+	}
+	if forecast != nil {
+		basePrompt += "\n" + forecast.PromptText()
+	} else {
+		basePrompt += "\nPlease retrieve current weather conditions for the specified city before rendering."
+		config.Tools = []*genai.Tool{
+			{GoogleSearch: &genai.GoogleSearch{}},
+		}
+	}
 
 	var prompt string
 	if extraContext != "" {
@@ -76,12 +120,7 @@ Please retrieve current weather conditions for the specified city before renderi
 
 	log.Printf("Generating image for city: %s using model: %s (GenerateContent)", city, model)
 
-	resp, err := s.client.Models.GenerateContent(ctx, model, genai.Text(prompt), &genai.GenerateContentConfig{
-		ResponseModalities: []string{"IMAGE"},
-		Tools: []*genai.Tool{
-			{GoogleSearch: &genai.GoogleSearch{}},
-		},
-	})
+	resp, err := s.client.Models.GenerateContent(ctx, model, genai.Text(prompt), config)
 	if err != nil {
 		log.Printf("GenAI GenerateContent failed: %v", err)
 		return "", fmt.Errorf("genai error: %w", err)
@@ -104,23 +143,34 @@ Please retrieve current weather conditions for the specified city before renderi
 	return "", fmt.Errorf("no image data found in response")
 }
 
-// GenerateVideo generates a 9:16 video using Veo 3.1 Fast.
-// Returns: GS URI (string) or error.
-func (s *Service) GenerateVideo(ctx context.Context, inputImageURI string, prompt string) (string, error) {
+// StartVideo starts generating a 9:16 video using Veo 3.1 Fast.
+// Returns: Veo operation name (string) or error.
+func (s *Service) StartVideo(ctx context.Context, inputImageURI string, prompt string) (string, error) {
 	model := "veo-3.1-fast-generate-preview"
 	
 	log.Printf("Generating video with model %s. Input: %s", model, inputImageURI)
 
-	// Construct the image object
+	// Construct the image object. Local files (storage.LocalService) are
+	// sent inline since Veo can only read from GCS.
 	image := &genai.Image{
 		GCSURI: inputImageURI,
 		MIMEType: "image/png",
 	}
+	if path, ok := strings.CutPrefix(inputImageURI, "file://"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read input image: %w", err)
+		}
+		image.GCSURI = ""
+		image.ImageBytes = data
+	}
 
 	// Config
 	config := &genai.GenerateVideosConfig{
 		AspectRatio: "9:16",
-		OutputGCSURI: fmt.Sprintf("gs://%s/videos/", s.bucketName),
+	}
+	if s.bucketName != "" {
+		config.OutputGCSURI = fmt.Sprintf("gs://%s/videos/", s.bucketName)
 	}
 
 	// Call GenerateVideos
@@ -131,6 +181,13 @@ func (s *Service) GenerateVideo(ctx context.Context, inputImageURI string, promp
 	}
 
 	log.Printf("Veo operation started. ID: %s", resp.Name)
+	return resp.Name, nil
+}
+
+// AwaitVideo polls a Veo operation until it completes.
+// Returns: GS URI (string), or public URL for inline videos, or error.
+func (s *Service) AwaitVideo(ctx context.Context, opName string) (string, error) {
+	resp := &genai.GenerateVideosOperation{Name: opName}
 
 	// Polling Loop using Native SDK method
 	ticker := time.NewTicker(5 * time.Second)
@@ -139,7 +196,7 @@ func (s *Service) GenerateVideo(ctx context.Context, inputImageURI string, promp
 	for {
 		select {
 		case <-ctx.Done():
-			return "", fmt.Errorf("context cancelled during polling")
+			return "", fmt.Errorf("context cancelled during polling: %w", ctx.Err())
 		case <-ticker.C:
 			// Use native SDK polling
 			op, err := s.client.Operations.GetVideosOperation(ctx, resp, nil)
@@ -184,6 +241,12 @@ func (s *Service) GenerateVideo(ctx context.Context, inputImageURI string, promp
 					return uri, nil
 				}
 
+				// No bucket configured: Veo returned the bytes inline.
+				if v.Video != nil && len(v.Video.VideoBytes) > 0 && s.videoStore != nil {
+					fileName := fmt.Sprintf("videos/veo_%d.mp4", time.Now().UnixNano())
+					return s.videoStore.UploadBytes(ctx, v.Video.VideoBytes, fileName, "video/mp4")
+				}
+
 				return "", fmt.Errorf("video generated but URI is empty (JSON: %s)", string(b))
 			}
 			log.Printf("Still polling Veo...")
@@ -0,0 +1,147 @@
+package genai
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"strings"
+	"time"
+
+	"banana-weather/pkg/storage"
+	"banana-weather/pkg/weather"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+//go:embed assets/placeholder.mp4
+var placeholderVideo []byte
+
+const (
+	placeholderWidth  = 576
+	placeholderHeight = 1024
+)
+
+// PlaceholderService implements ImageGenerator and VideoGenerator without
+// calling any model. Images are deterministic for a given city and day, and
+// videos are a canned clip written to the blob store.
+type PlaceholderService struct {
+	blobs storage.BlobStore
+}
+
+func NewPlaceholderService(blobs storage.BlobStore) *PlaceholderService {
+	return &PlaceholderService{blobs: blobs}
+}
+
+// GenerateImage renders a 9:16 PNG with the city name, today's date and the
+// forecast, if one is given.
+func (s *PlaceholderService) GenerateImage(ctx context.Context, city string, extraContext string, forecast *weather.Forecast) (string, error) {
+	log.Printf("Generating placeholder image for city: %s", city)
+
+	h := fnv.New32a()
+	h.Write([]byte(city))
+	seed := h.Sum32()
+
+	top := color.RGBA{R: 255, G: 225, B: 53, A: 255} // Banana yellow
+	bottom := color.RGBA{R: uint8(seed), G: uint8(seed >> 8), B: uint8(seed >> 16), A: 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, placeholderWidth, placeholderHeight))
+	for y := 0; y < placeholderHeight; y++ {
+		c := lerpColor(top, bottom, float64(y)/float64(placeholderHeight-1))
+		draw.Draw(img, image.Rect(0, y, placeholderWidth, y+1), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	ink := color.RGBA{R: 40, G: 40, B: 40, A: 255}
+	drawCenteredText(img, city, 180, 5, ink)
+	drawCenteredText(img, time.Now().Format("Monday, January 2, 2006"), 260, 2, ink)
+	if forecast != nil {
+		// basicfont has no degree sign.
+		drawCenteredText(img, forecast.Condition, 340, 3, ink)
+		drawCenteredText(img, fmt.Sprintf("%.0fC  (%.0f / %.0f)", forecast.Temperature, forecast.Low, forecast.High), 400, 3, ink)
+	}
+	drawCenteredText(img, "offline placeholder", placeholderHeight-80, 2, ink)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode placeholder: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// placeholderOpPrefix marks placeholder operation names; the rest of the name
+// is the input image URI.
+const placeholderOpPrefix = "placeholder:"
+
+// StartVideo returns an operation name that AwaitVideo completes immediately.
+func (s *PlaceholderService) StartVideo(ctx context.Context, inputImageURI string, prompt string) (string, error) {
+	return placeholderOpPrefix + inputImageURI, nil
+}
+
+// AwaitVideo stores the canned clip and returns its URL.
+func (s *PlaceholderService) AwaitVideo(ctx context.Context, opName string) (string, error) {
+	inputImageURI, ok := strings.CutPrefix(opName, placeholderOpPrefix)
+	if !ok {
+		return "", fmt.Errorf("unknown placeholder operation %q", opName)
+	}
+	if s.blobs == nil {
+		return "", fmt.Errorf("placeholder video requires a blob store")
+	}
+	h := fnv.New32a()
+	h.Write([]byte(inputImageURI))
+	fileName := fmt.Sprintf("videos/placeholder_%08x.mp4", h.Sum32())
+
+	log.Printf("Writing placeholder video for %s", inputImageURI)
+	return s.blobs.UploadBytes(ctx, placeholderVideo, fileName, "video/mp4")
+}
+
+func lerpColor(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*t) }
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 255}
+}
+
+// drawCenteredText draws text horizontally centered at baseline y, scaling the
+// 7x13 bitmap font up by at most maxScale while keeping it inside the image.
+func drawCenteredText(dst *image.RGBA, text string, y int, maxScale int, col color.Color) {
+	face := basicfont.Face7x13
+	text = strings.TrimSpace(text)
+	width := font.MeasureString(face, text).Ceil()
+	if width == 0 {
+		return
+	}
+
+	scale := maxScale
+	for scale > 1 && width*scale > dst.Bounds().Dx()-40 {
+		scale--
+	}
+
+	// Render at 1x, then blow it up with nearest-neighbour sampling.
+	small := image.NewAlpha(image.Rect(0, 0, width, face.Height))
+	d := font.Drawer{
+		Dst:  small,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	d.DrawString(text)
+
+	x0 := (dst.Bounds().Dx() - width*scale) / 2
+	y0 := y - face.Ascent*scale
+	for sy := 0; sy < face.Height; sy++ {
+		for sx := 0; sx < width; sx++ {
+			if small.AlphaAt(sx, sy).A == 0 {
+				continue
+			}
+			r := image.Rect(x0+sx*scale, y0+sy*scale, x0+(sx+1)*scale, y0+(sy+1)*scale)
+			draw.Draw(dst, r, image.NewUniform(col), image.Point{}, draw.Src)
+		}
+	}
+}
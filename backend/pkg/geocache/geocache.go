@@ -0,0 +1,286 @@
+package geocache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/maps"
+	"banana-weather/pkg/slug"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// DefaultSize is how many results are kept in memory by default.
+	DefaultSize = 10000
+	// DefaultTTL is how long a result is reused by default. Maps allows
+	// caching coordinates for up to 30 days.
+	DefaultTTL = 30 * 24 * time.Hour
+	// DefaultNegativeTTL is how long "not found" is remembered by default.
+	DefaultNegativeTTL = time.Hour
+)
+
+// coordinatePrecision is the number of decimals reverse geocoding requests
+// are rounded to for the cache key (0.01° is about 1 km).
+const coordinatePrecision = 2
+
+// metrics counts the lookups of every Cache, published with expvar (served
+// at /api/admin/vars): "memory_hits" and "store_hits" found a result,
+// "negative_hits" a remembered "not found", "misses" called the geocoder and
+// "errors" counts failed geocoder calls, which are not cached.
+var metrics = expvar.NewMap("geocode_cache")
+
+// Cache is a maps.Geocoder that remembers the results of another one: in an
+// LRU in memory, and in a GeocodeStore shared by all instances. Queries that
+// found nothing are remembered for a shorter time.
+type Cache struct {
+	next        maps.Geocoder
+	store       database.GeocodeStore // Optional
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element // Of *database.Geocode
+	lru     *list.List               // Most recently used first
+}
+
+// New returns a Cache in front of next, keeping up to size results in memory
+// and, if store is not nil, all of them in store.
+func New(next maps.Geocoder, store database.GeocodeStore, size int, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		next:        next,
+		store:       store,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		size:        size,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+// NewFromEnv returns a Cache configured by GEOCODE_CACHE_SIZE (default
+// 10000), GEOCODE_CACHE_TTL (default 720h) and GEOCODE_CACHE_NEGATIVE_TTL
+// (default 1h). A TTL of 0 disables that kind of caching. Results are shared
+// through store unless GEOCODE_CACHE_STORE=memory.
+func NewFromEnv(next maps.Geocoder, store database.GeocodeStore) (*Cache, error) {
+	size := DefaultSize
+	if v := os.Getenv("GEOCODE_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GEOCODE_CACHE_SIZE %q", v)
+		}
+		size = n
+	}
+
+	ttl, negativeTTL := DefaultTTL, DefaultNegativeTTL
+	for name, d := range map[string]*time.Duration{
+		"GEOCODE_CACHE_TTL":          &ttl,
+		"GEOCODE_CACHE_NEGATIVE_TTL": &negativeTTL,
+	} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid %s %q", name, v)
+		}
+		*d = parsed
+	}
+
+	switch v := os.Getenv("GEOCODE_CACHE_STORE"); v {
+	case "", "database":
+	case "memory":
+		store = nil
+	default:
+		return nil, fmt.Errorf("unknown GEOCODE_CACHE_STORE %q (want database or memory)", v)
+	}
+	return New(next, store, size, ttl, negativeTTL), nil
+}
+
+// FindCities geocodes query, keyed by its normalized text. The candidates
+// are also cached by place ID, for when the client picks one of them.
+func (c *Cache) FindCities(ctx context.Context, query string) ([]maps.Place, error) {
+	key := "q_" + slug.Hash(NormalizeQuery(query), 32)
+	return c.lookup(ctx, key, "city", func() ([]maps.Place, error) {
+		places, err := c.next.FindCities(ctx, query)
+		if err == nil && len(places) > 1 {
+			for _, p := range places {
+				c.remember(ctx, placeKey(p.ID), []maps.Place{p})
+			}
+		}
+		return places, err
+	})
+}
+
+// GetPlace looks up placeID, keyed by the place ID.
+func (c *Cache) GetPlace(ctx context.Context, placeID string) (maps.Place, error) {
+	return c.lookupOne(ctx, placeKey(placeID), "place", func() (maps.Place, error) {
+		return c.next.GetPlace(ctx, placeID)
+	})
+}
+
+// GetReverseGeocoding reverse geocodes lat, lng, keyed by the coordinates
+// rounded to about a kilometer.
+func (c *Cache) GetReverseGeocoding(ctx context.Context, lat, lng float64) (maps.Place, error) {
+	key := fmt.Sprintf("ll_%.*f_%.*f", coordinatePrecision, lat, coordinatePrecision, lng)
+	return c.lookupOne(ctx, key, "location", func() (maps.Place, error) {
+		return c.next.GetReverseGeocoding(ctx, lat, lng)
+	})
+}
+
+// placeKey is the cache key of a place ID, hashed since place IDs may be
+// longer than a document ID should be.
+func placeKey(placeID string) string {
+	return "p_" + slug.Hash(placeID, 32)
+}
+
+// NormalizeQuery folds the spelling differences that don't change what a
+// query means: case, Unicode normalization and spacing, e.g.
+// "  san  Francisco ,CA" -> "san francisco, ca".
+func NormalizeQuery(q string) string {
+	parts := strings.Split(norm.NFC.String(strings.ToLower(q)), ",")
+	for i, p := range parts {
+		parts[i] = strings.Join(strings.Fields(p), " ")
+	}
+	return strings.Trim(strings.Join(parts, ", "), ", ")
+}
+
+// lookupOne is lookup for geocoders that return a single place.
+func (c *Cache) lookupOne(ctx context.Context, key, what string, geocode func() (maps.Place, error)) (maps.Place, error) {
+	places, err := c.lookup(ctx, key, what, func() ([]maps.Place, error) {
+		place, err := geocode()
+		if err != nil {
+			return nil, err
+		}
+		return []maps.Place{place}, nil
+	})
+	if err != nil {
+		return maps.Place{}, err
+	}
+	return places[0], nil
+}
+
+// lookup returns the cached result for key, or calls geocode and caches its
+// result. what names the thing not found in a cached "not found" error.
+func (c *Cache) lookup(ctx context.Context, key, what string, geocode func() ([]maps.Place, error)) ([]maps.Place, error) {
+	now := time.Now()
+	if g, ok := c.get(key, now); ok {
+		return c.hit("memory_hits", what, g)
+	}
+	if c.store != nil {
+		g, err := c.store.GetGeocode(ctx, key)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			log.Printf("Failed to read cached geocode %s: %v", key, err)
+		}
+		// Entries without places predate candidate lists.
+		if err == nil && now.Before(g.ExpiresAt) && (g.NotFound || len(g.Places) > 0) {
+			c.put(*g)
+			return c.hit("store_hits", what, g)
+		}
+	}
+
+	metrics.Add("misses", 1)
+	places, err := geocode()
+	switch {
+	case err == nil:
+		c.remember(ctx, key, places)
+	case errors.Is(err, maps.ErrNotFound):
+		if c.negativeTTL > 0 {
+			c.save(ctx, database.Geocode{Key: key, NotFound: true, ExpiresAt: now.Add(c.negativeTTL)})
+		}
+	default:
+		metrics.Add("errors", 1)
+	}
+	return places, err
+}
+
+// remember caches places as the result for key.
+func (c *Cache) remember(ctx context.Context, key string, places []maps.Place) {
+	if c.ttl == 0 || len(places) == 0 {
+		return
+	}
+	g := database.Geocode{Key: key, ExpiresAt: time.Now().Add(c.ttl)}
+	for _, p := range places {
+		g.Places = append(g.Places, database.GeocodedPlace{
+			PlaceID: p.ID, Name: p.Name, Country: p.Country, Lat: p.Lat, Lng: p.Lng,
+		})
+	}
+	c.save(ctx, g)
+}
+
+// save adds g to memory and the store.
+func (c *Cache) save(ctx context.Context, g database.Geocode) {
+	c.put(g)
+	if c.store != nil {
+		if err := c.store.PutGeocode(ctx, g); err != nil {
+			log.Printf("Failed to cache geocode %s: %v", g.Key, err)
+		}
+	}
+}
+
+// hit returns a cached result and counts it as metric, or as a negative hit.
+func (c *Cache) hit(metric, what string, g *database.Geocode) ([]maps.Place, error) {
+	if g.NotFound {
+		metrics.Add("negative_hits", 1)
+		return nil, fmt.Errorf("%s %w", what, maps.ErrNotFound)
+	}
+	metrics.Add(metric, 1)
+	places := make([]maps.Place, len(g.Places))
+	for i, p := range g.Places {
+		places[i] = maps.Place{ID: p.PlaceID, Name: p.Name, Country: p.Country, Lat: p.Lat, Lng: p.Lng}
+	}
+	return places, nil
+}
+
+// get returns the unexpired entry for key from memory.
+func (c *Cache) get(key string, now time.Time) (*database.Geocode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	g := e.Value.(*database.Geocode)
+	if !now.Before(g.ExpiresAt) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return g, true
+}
+
+// put adds g to memory, evicting the least recently used entry if full.
+func (c *Cache) put(g database.Geocode) {
+	if c.size == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[g.Key]; ok {
+		e.Value = &g
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[g.Key] = c.lru.PushFront(&g)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*database.Geocode).Key)
+	}
+}
@@ -0,0 +1,206 @@
+package geocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/maps"
+)
+
+// countingGeocoder knows a few places and counts the calls made to it.
+type countingGeocoder struct {
+	calls int
+	err   error // Returned by every call if set
+}
+
+var (
+	springfieldIL = maps.Place{ID: "place-il", Name: "Springfield, IL, USA", Country: "US", Lat: 39.80, Lng: -89.64}
+	springfieldMO = maps.Place{ID: "place-mo", Name: "Springfield, MO, USA", Country: "US", Lat: 37.21, Lng: -93.29}
+	paris         = maps.Place{ID: "place-paris", Name: "Paris, France", Country: "FR", Lat: 48.85, Lng: 2.35}
+)
+
+func (g *countingGeocoder) FindCities(ctx context.Context, query string) ([]maps.Place, error) {
+	g.calls++
+	if g.err != nil {
+		return nil, g.err
+	}
+	switch NormalizeQuery(query) {
+	case "springfield":
+		return []maps.Place{springfieldIL, springfieldMO}, nil
+	case "paris":
+		return []maps.Place{paris}, nil
+	}
+	return nil, fmt.Errorf("city %w", maps.ErrNotFound)
+}
+
+func (g *countingGeocoder) GetPlace(ctx context.Context, placeID string) (maps.Place, error) {
+	g.calls++
+	if g.err != nil {
+		return maps.Place{}, g.err
+	}
+	for _, p := range []maps.Place{springfieldIL, springfieldMO, paris} {
+		if p.ID == placeID {
+			return p, nil
+		}
+	}
+	return maps.Place{}, fmt.Errorf("place %w", maps.ErrNotFound)
+}
+
+func (g *countingGeocoder) GetReverseGeocoding(ctx context.Context, lat, lng float64) (maps.Place, error) {
+	g.calls++
+	if g.err != nil {
+		return maps.Place{}, g.err
+	}
+	return paris, nil
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := map[string]string{
+		"  san  Francisco ,CA": "san francisco, ca",
+		"San Francisco, CA":    "san francisco, ca",
+		"MÜNCHEN":              "münchen",
+		"Mu\u0308nchen":        "münchen", // Decomposed ü
+		"Paris,":               "paris",
+		"":                     "",
+	}
+	for q, want := range tests {
+		if got := NormalizeQuery(q); got != want {
+			t.Errorf("NormalizeQuery(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestFindCities(t *testing.T) {
+	ctx := context.Background()
+	next := &countingGeocoder{}
+	store := database.NewMemoryStore()
+	c := New(next, store, DefaultSize, DefaultTTL, DefaultNegativeTTL)
+
+	for _, q := range []string{"Springfield", "  springfield ", "SPRINGFIELD"} {
+		places, err := c.FindCities(ctx, q)
+		if err != nil || len(places) != 2 || places[0] != springfieldIL || places[1] != springfieldMO {
+			t.Errorf("FindCities(%q) = %+v, %v; want both Springfields", q, places, err)
+		}
+	}
+	// Picking a candidate needs no further call.
+	if place, err := c.GetPlace(ctx, springfieldMO.ID); err != nil || place != springfieldMO {
+		t.Errorf("GetPlace(%s) = %+v, %v", springfieldMO.ID, place, err)
+	}
+	if next.calls != 1 {
+		t.Errorf("geocoder called %d times, want once", next.calls)
+	}
+
+	// Another instance finds the results in the store.
+	other := New(next, store, DefaultSize, DefaultTTL, DefaultNegativeTTL)
+	if places, err := other.FindCities(ctx, "Springfield"); err != nil || len(places) != 2 {
+		t.Errorf("FindCities from the store = %+v, %v", places, err)
+	}
+	if place, err := other.GetPlace(ctx, springfieldIL.ID); err != nil || place != springfieldIL {
+		t.Errorf("GetPlace from the store = %+v, %v", place, err)
+	}
+	if next.calls != 1 {
+		t.Errorf("geocoder called %d times, want once", next.calls)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	next := &countingGeocoder{}
+	c := New(next, database.NewMemoryStore(), DefaultSize, DefaultTTL, DefaultNegativeTTL)
+
+	for range 2 {
+		if _, err := c.FindCities(ctx, "Atlantis"); !errors.Is(err, maps.ErrNotFound) {
+			t.Errorf("FindCities(Atlantis): %v, want ErrNotFound", err)
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("geocoder called %d times, want once", next.calls)
+	}
+
+	// Failures are not cached.
+	next.err = errors.New("quota exceeded")
+	for range 2 {
+		if _, err := c.FindCities(ctx, "Paris"); err != next.err {
+			t.Errorf("FindCities(Paris) with the geocoder down: %v", err)
+		}
+	}
+	next.err = nil
+	if places, err := c.FindCities(ctx, "Paris"); err != nil || len(places) != 1 {
+		t.Errorf("FindCities(Paris) after recovery = %+v, %v", places, err)
+	}
+	if next.calls != 4 {
+		t.Errorf("geocoder called %d times, want 4", next.calls)
+	}
+}
+
+func TestGetReverseGeocoding(t *testing.T) {
+	ctx := context.Background()
+	next := &countingGeocoder{}
+	c := New(next, nil, DefaultSize, DefaultTTL, DefaultNegativeTTL)
+
+	// Both round to 48.86, 2.35.
+	for _, ll := range [][2]float64{{48.8566, 2.3522}, {48.8571, 2.3519}} {
+		if place, err := c.GetReverseGeocoding(ctx, ll[0], ll[1]); err != nil || place != paris {
+			t.Errorf("GetReverseGeocoding(%v) = %+v, %v", ll, place, err)
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("geocoder called %d times, want once", next.calls)
+	}
+	c.GetReverseGeocoding(ctx, 48.8766, 2.3522)
+	if next.calls != 2 {
+		t.Errorf("geocoder called %d times for coordinates a kilometer apart, want twice", next.calls)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	next := &countingGeocoder{}
+	c := New(next, nil, 2, DefaultTTL, DefaultNegativeTTL)
+
+	c.GetPlace(ctx, paris.ID)
+	c.GetPlace(ctx, springfieldIL.ID)
+	c.GetPlace(ctx, paris.ID) // Now the most recently used
+	c.GetPlace(ctx, springfieldMO.ID)
+	if next.calls != 3 {
+		t.Fatalf("geocoder called %d times, want 3", next.calls)
+	}
+	c.GetPlace(ctx, paris.ID)
+	if next.calls != 3 {
+		t.Errorf("recently used entry was evicted")
+	}
+	c.GetPlace(ctx, springfieldIL.ID)
+	if next.calls != 4 {
+		t.Errorf("least recently used entry was kept")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	next := &countingGeocoder{}
+	store := database.NewMemoryStore()
+	store.PutGeocode(ctx, database.Geocode{
+		Key:       placeKey(paris.ID),
+		Places:    []database.GeocodedPlace{{PlaceID: paris.ID, Name: "Paris (stale)"}},
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	c := New(next, store, DefaultSize, DefaultTTL, DefaultNegativeTTL)
+	if place, err := c.GetPlace(ctx, paris.ID); err != nil || place != paris {
+		t.Errorf("GetPlace with an expired entry = %+v, %v; want a fresh lookup", place, err)
+	}
+
+	// A TTL of 0 disables caching.
+	off := New(next, store, DefaultSize, 0, 0)
+	next.calls = 0
+	for range 2 {
+		off.FindCities(ctx, "Springfield")
+		off.FindCities(ctx, "Atlantis")
+	}
+	if next.calls != 4 {
+		t.Errorf("geocoder called %d times with caching off, want 4", next.calls)
+	}
+}
@@ -0,0 +1,432 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"banana-weather/pkg/database"
+)
+
+const (
+	// Events larger than this (e.g. inline base64 images) are kept in memory
+	// only; Firestore documents are limited to 1 MiB.
+	maxPersistedEventBytes = 64 << 10
+
+	// How long persisted jobs are kept (see Job.ExpiresAt).
+	jobTTL = 24 * time.Hour
+
+	// When following a job owned by another instance, how often to re-read it
+	// and after how long without updates it is considered abandoned.
+	remotePollInterval = 2 * time.Second
+	remoteStaleAfter   = 15 * time.Minute
+)
+
+// How long a cross-instance lease (see Handle.Lead) is valid. The holder
+// renews it every leaseRenewInterval for as long as the job runs, however long
+// that is (e.g. VIDEO_TIMEOUT), and releases it when the job finishes. A lease
+// outlives a crashed holder by at most leaseTTL. Tests shorten them.
+var (
+	leaseTTL           = 2 * time.Minute
+	leaseRenewInterval = leaseTTL / 3
+)
+
+// ErrEnded is returned by Follow when the job has finished and there are no
+// events after the given one.
+var ErrEnded = errors.New("job has ended")
+
+// Manager runs generation jobs in the background, independent of the HTTP
+// request that started them, and lets any number of clients follow a job's
+// events, including clients that reconnect after a dropped connection.
+//
+// Jobs are persisted after every change, so a client can also resume a job
+// that is running on (or finished by) another server instance.
+type Manager struct {
+	store     database.JobStore
+	leases    database.LeaseStore
+	ctx       context.Context
+	retention time.Duration
+
+	mu      sync.Mutex
+	live    map[string]*liveJob
+	leaders map[string]string // Lead key -> ID of the local job holding it
+}
+
+type liveJob struct {
+	mu      sync.Mutex
+	job     database.Job
+	changed chan struct{} // Closed and replaced on every change
+
+	persistMu sync.Mutex // Keeps store writes in order
+}
+
+// NewManager creates a Manager. Jobs run with ctx, which should be owned by
+// the server rather than by any single request.
+func NewManager(ctx context.Context, store database.JobStore) *Manager {
+	return &Manager{
+		store:     store,
+		ctx:       ctx,
+		retention: 10 * time.Minute,
+		live:      make(map[string]*liveJob),
+		leaders:   make(map[string]string),
+	}
+}
+
+// SetLeaseStore enables coalescing across server instances (see Handle.Lead).
+// Without it jobs are only coalesced within this process.
+func (m *Manager) SetLeaseStore(leases database.LeaseStore) {
+	m.leases = leases
+}
+
+// Handle is passed to a job's run function to report progress.
+type Handle struct {
+	m     *Manager
+	lj    *liveJob
+	leads []string // Keys to release when the job finishes
+
+	renewing chan struct{} // Closed when the job finishes; nil until leases are held
+	renewals sync.WaitGroup
+}
+
+// Start creates a job for city and executes run in the background. The first
+// event of every job is "job", carrying the job ID; the last is "done".
+func (m *Manager) Start(city string, run func(ctx context.Context, h *Handle)) string {
+	now := time.Now()
+	lj := &liveJob{
+		job: database.Job{
+			ID:        newID(),
+			City:      city,
+			Stage:     database.JobQueued,
+			CreatedAt: now,
+			UpdatedAt: now,
+			ExpiresAt: now.Add(jobTTL),
+		},
+		changed: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.live[lj.job.ID] = lj
+	m.mu.Unlock()
+
+	h := &Handle{m: m, lj: lj}
+	h.Emit("job", lj.job.ID)
+
+	go func() {
+		defer h.finish()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Job %s panicked: %v", lj.job.ID, r)
+				h.Fail("Internal error")
+			}
+		}()
+		run(m.ctx, h)
+	}()
+
+	return lj.job.ID
+}
+
+// ID returns the job ID.
+func (h *Handle) ID() string {
+	return h.lj.job.ID
+}
+
+// Emit appends an event to the job and wakes up followers.
+func (h *Handle) Emit(event, data string) {
+	h.change(func(job *database.Job) {
+		appendEvent(job, event, data)
+	})
+}
+
+func appendEvent(job *database.Job, event, data string) {
+	job.Events = append(job.Events, database.JobEvent{
+		ID:    len(job.Events) + 1,
+		Event: event,
+		Data:  data,
+	})
+}
+
+// Update modifies job fields (stage, URLs, ...) and persists them.
+func (h *Handle) Update(fn func(job *database.Job)) {
+	h.change(fn)
+}
+
+// Fail marks the job as failed and emits an "error" event.
+func (h *Handle) Fail(msg string) {
+	h.FailWith(msg, msg)
+}
+
+// FailWith is like Fail but emits data (e.g. a structured error) as the
+// "error" event instead of msg.
+func (h *Handle) FailWith(msg, data string) {
+	h.change(func(job *database.Job) {
+		job.Stage = database.JobFailed
+		job.Error = msg
+	})
+	h.Emit("error", data)
+}
+
+// Lead makes this job the one working on key (e.g. a location ID), unless
+// another job already is, in which case that job's ID is returned so the
+// caller can follow it instead. Jobs on this instance are checked first, then
+// the lease store shared with other instances. Leadership is released when the
+// job finishes.
+func (h *Handle) Lead(ctx context.Context, key string) (string, error) {
+	m := h.m
+	id := h.ID()
+
+	m.mu.Lock()
+	if other, ok := m.leaders[key]; ok && other != id {
+		m.mu.Unlock()
+		return other, nil
+	}
+	m.leaders[key] = id
+	m.mu.Unlock()
+	h.leads = append(h.leads, key)
+
+	// Other local jobs now follow this one; if another instance is already
+	// working on key, this job in turn follows that one.
+	if m.leases == nil {
+		return id, nil
+	}
+	holder, err := m.leases.AcquireLease(ctx, key, id, leaseTTL)
+	if err != nil {
+		return id, fmt.Errorf("failed to acquire lease for %s: %w", key, err)
+	}
+	if holder == id {
+		h.renew(key)
+	}
+	return holder, nil
+}
+
+// renew keeps the lease on key until the job finishes.
+func (h *Handle) renew(key string) {
+	if h.renewing == nil {
+		h.renewing = make(chan struct{})
+	}
+	stop := h.renewing
+	id := h.ID()
+
+	h.renewals.Add(1)
+	go func() {
+		defer h.renewals.Done()
+		ticker := time.NewTicker(leaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-h.m.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			holder, err := h.m.leases.AcquireLease(h.m.ctx, key, id, leaseTTL)
+			if err != nil {
+				log.Printf("Failed to renew lease %s: %v", key, err)
+			} else if holder != id {
+				log.Printf("Lost lease %s to job %s", key, holder)
+				return
+			}
+		}
+	}()
+}
+
+func (h *Handle) release() {
+	m := h.m
+	id := h.ID()
+
+	m.mu.Lock()
+	for _, key := range h.leads {
+		if m.leaders[key] == id {
+			delete(m.leaders, key)
+		}
+	}
+	m.mu.Unlock()
+
+	if m.leases == nil {
+		return
+	}
+	if h.renewing != nil {
+		// Don't let a renewal recreate a lease after it is released.
+		close(h.renewing)
+		h.renewals.Wait()
+	}
+	for _, key := range h.leads {
+		if err := m.leases.ReleaseLease(m.ctx, key, id); err != nil {
+			log.Printf("Failed to release lease %s: %v", key, err)
+		}
+	}
+}
+
+// finish moves the job to a terminal stage and emits "done" in a single
+// change, so followers never see a finished job without its final event.
+func (h *Handle) finish() {
+	h.change(func(job *database.Job) {
+		if job.Stage != database.JobFailed {
+			job.Stage = database.JobDone
+		}
+		appendEvent(job, "done", job.ID)
+	})
+	h.release()
+
+	id := h.lj.job.ID
+	time.AfterFunc(h.m.retention, func() {
+		h.m.mu.Lock()
+		delete(h.m.live, id)
+		h.m.mu.Unlock()
+	})
+}
+
+func (h *Handle) change(fn func(job *database.Job)) {
+	lj := h.lj
+	lj.persistMu.Lock()
+	defer lj.persistMu.Unlock()
+
+	lj.mu.Lock()
+	fn(&lj.job)
+	lj.job.UpdatedAt = time.Now()
+	snapshot := persistable(lj.job)
+	close(lj.changed)
+	lj.changed = make(chan struct{})
+	lj.mu.Unlock()
+
+	if err := h.m.store.UpsertJob(h.m.ctx, snapshot); err != nil {
+		log.Printf("Failed to persist job %s: %v", snapshot.ID, err)
+	}
+}
+
+// persistable copies a job, dropping event payloads that are too large to store.
+func persistable(job database.Job) database.Job {
+	events := make([]database.JobEvent, len(job.Events))
+	for i, e := range job.Events {
+		if len(e.Data) > maxPersistedEventBytes {
+			e.Data = ""
+			e.Omitted = true
+		}
+		events[i] = e
+	}
+	job.Events = events
+	return job
+}
+
+// Get returns a snapshot of a job, from memory if it runs on this instance.
+func (m *Manager) Get(ctx context.Context, id string) (*database.Job, error) {
+	if lj := m.lookup(id); lj != nil {
+		lj.mu.Lock()
+		job := lj.job
+		job.Events = append([]database.JobEvent(nil), lj.job.Events...)
+		lj.mu.Unlock()
+		return &job, nil
+	}
+	return m.store.GetJob(ctx, id)
+}
+
+// Follow calls send for every event with an ID greater than after, waiting
+// for new events until the job finishes or ctx is cancelled. If the job has
+// already finished and after is its last event (e.g. an EventSource
+// reconnecting after "done"), it returns ErrEnded at once.
+func (m *Manager) Follow(ctx context.Context, id string, after int, send func(database.JobEvent) error) error {
+	if lj := m.lookup(id); lj != nil {
+		return followLive(ctx, lj, after, send)
+	}
+	return m.followStored(ctx, id, after, send)
+}
+
+func (m *Manager) lookup(id string) *liveJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.live[id]
+}
+
+func followLive(ctx context.Context, lj *liveJob, after int, send func(database.JobEvent) error) error {
+	for {
+		lj.mu.Lock()
+		var pending []database.JobEvent
+		if after < len(lj.job.Events) {
+			pending = append(pending, lj.job.Events[after:]...)
+		}
+		changed := lj.changed
+		over := ended(&lj.job)
+		lj.mu.Unlock()
+
+		if len(pending) == 0 && over {
+			return ErrEnded
+		}
+		for _, e := range pending {
+			if err := send(e); err != nil {
+				return err
+			}
+			after = e.ID
+			if e.Event == "done" {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// followStored replays a job that is not running on this instance, polling
+// the store for progress made elsewhere.
+func (m *Manager) followStored(ctx context.Context, id string, after int, send func(database.JobEvent) error) error {
+	ticker := time.NewTicker(remotePollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := m.store.GetJob(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range job.Events {
+			if e.ID <= after {
+				continue
+			}
+			after = e.ID
+			if e.Omitted {
+				continue
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+			if e.Event == "done" {
+				return nil
+			}
+		}
+		if ended(job) {
+			return ErrEnded
+		}
+		if time.Since(job.UpdatedAt) > remoteStaleAfter {
+			return send(database.JobEvent{ID: after + 1, Event: "error", Data: "Generation was interrupted. Please try again."})
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ended reports whether job has emitted its final "done" event. Failed jobs
+// are Finished before that.
+func ended(job *database.Job) bool {
+	n := len(job.Events)
+	return job.Finished() && n > 0 && job.Events[n-1].Event == "done"
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,282 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"banana-weather/pkg/database"
+)
+
+// collect follows job id after event after and returns the events sent.
+func collect(t *testing.T, m *Manager, id string, after int) ([]database.JobEvent, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var events []database.JobEvent
+	err := m.Follow(ctx, id, after, func(e database.JobEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+func eventNames(events []database.JobEvent) string {
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestFollow(t *testing.T) {
+	m := NewManager(context.Background(), database.NewMemoryStore())
+	proceed := make(chan struct{})
+	id := m.Start("Paris", func(ctx context.Context, h *Handle) {
+		h.Emit("status", "working")
+		<-proceed
+		h.Update(func(j *database.Job) { j.ImageURL = "http://media.test/paris.png" })
+		h.Emit("result", "{}")
+	})
+
+	// Followers wait for events that haven't happened yet.
+	go close(proceed)
+	events, err := collect(t, m, id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventNames(events); got != "job status result done" {
+		t.Fatalf("events %q, want job status result done", got)
+	}
+	for i, e := range events {
+		if e.ID != i+1 {
+			t.Errorf("event %d has ID %d", i, e.ID)
+		}
+	}
+	if events[0].Data != id || events[3].Data != id {
+		t.Errorf("job and done events carry %q and %q, want the job ID", events[0].Data, events[3].Data)
+	}
+
+	// Resuming replays what was missed.
+	if events, err := collect(t, m, id, 2); err != nil || eventNames(events) != "result done" {
+		t.Errorf("after 2: %q, %v; want result done", eventNames(events), err)
+	}
+	// Resuming after done has nothing left to send.
+	if events, err := collect(t, m, id, 4); !errors.Is(err, ErrEnded) || len(events) != 0 {
+		t.Errorf("after done: %q, %v; want ErrEnded", eventNames(events), err)
+	}
+
+	job, err := m.Get(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Stage != database.JobDone || job.ImageURL != "http://media.test/paris.png" || job.City != "Paris" {
+		t.Errorf("job %+v, want done with its image", job)
+	}
+}
+
+func TestFollowFailed(t *testing.T) {
+	m := NewManager(context.Background(), database.NewMemoryStore())
+	for _, run := range []func(ctx context.Context, h *Handle){
+		func(ctx context.Context, h *Handle) { h.Fail("City not found") },
+		func(ctx context.Context, h *Handle) { panic("boom") },
+	} {
+		id := m.Start("Atlantis", run)
+		events, err := collect(t, m, id, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := eventNames(events); got != "job error done" {
+			t.Errorf("events %q, want job error done", got)
+		}
+		job, _ := m.Get(context.Background(), id)
+		if job.Stage != database.JobFailed || job.Error == "" {
+			t.Errorf("job %+v, want failed with an error", job)
+		}
+	}
+}
+
+func TestFollowStored(t *testing.T) {
+	store := database.NewMemoryStore()
+	m := NewManager(context.Background(), store)
+	big := strings.Repeat("x", maxPersistedEventBytes+1)
+	id := m.Start("Paris", func(ctx context.Context, h *Handle) {
+		h.Emit("result", big)
+		h.Emit("video", "http://media.test/paris.mp4")
+	})
+	if _, err := collect(t, m, id, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance only has the store. Events too large to store are
+	// skipped.
+	other := NewManager(context.Background(), store)
+	events, err := collect(t, other, id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventNames(events); got != "job video done" {
+		t.Errorf("events from the store %q, want job video done", got)
+	}
+	if events, err := collect(t, other, id, 3); err != nil || eventNames(events) != "done" {
+		t.Errorf("after 3 from the store: %q, %v; want done", eventNames(events), err)
+	}
+	if _, err := collect(t, other, id, 4); !errors.Is(err, ErrEnded) {
+		t.Errorf("after done from the store: %v, want ErrEnded", err)
+	}
+	if _, err := collect(t, other, "nonexistent", 0); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("unknown job: %v, want ErrNotFound", err)
+	}
+}
+
+func TestFollowCanceled(t *testing.T) {
+	m := NewManager(context.Background(), database.NewMemoryStore())
+	release := make(chan struct{})
+	defer close(release)
+	id := m.Start("Paris", func(ctx context.Context, h *Handle) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := m.Follow(ctx, id, 0, func(database.JobEvent) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Follow of a running job after its context ended: %v", err)
+	}
+
+	// A send error, e.g. from a closed connection, stops following.
+	sendErr := errors.New("connection closed")
+	if err := m.Follow(context.Background(), id, 0, func(database.JobEvent) error { return sendErr }); err != sendErr {
+		t.Errorf("Follow with a failing send: %v, want its error", err)
+	}
+}
+
+// leader starts a job on m that leads key, reports the leader it got and
+// runs until release is closed.
+func leader(t *testing.T, m *Manager, key string, release <-chan struct{}) (id, leaderID string) {
+	t.Helper()
+	got := make(chan string, 1)
+	id = m.Start(key, func(ctx context.Context, h *Handle) {
+		leaderID, err := h.Lead(ctx, key)
+		if err != nil {
+			t.Errorf("Lead(%s): %v", key, err)
+		}
+		got <- leaderID
+		<-release
+	})
+	return id, <-got
+}
+
+// released waits until job id has finished and let go of key, locally and in
+// the lease store.
+func released(t *testing.T, m *Manager, id, key string) {
+	t.Helper()
+	if _, err := collect(t, m, id, 0); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still holds %s", id, key)
+		}
+		m.mu.Lock()
+		held := m.leaders[key] == id
+		m.mu.Unlock()
+		if held {
+			continue
+		}
+		if m.leases == nil {
+			return
+		}
+		holder, err := m.leases.AcquireLease(context.Background(), key, "probe", leaseTTL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if holder == "probe" {
+			m.leases.ReleaseLease(context.Background(), key, "probe")
+		}
+		if holder != id {
+			return
+		}
+	}
+}
+
+func TestLead(t *testing.T) {
+	m := NewManager(context.Background(), database.NewMemoryStore())
+	release := make(chan struct{})
+	first, leaderID := leader(t, m, "paris", release)
+	if leaderID != first {
+		t.Fatalf("first job got leader %s, want itself", leaderID)
+	}
+	if second, leaderID := leader(t, m, "paris", release); leaderID != first || second == first {
+		t.Errorf("second job got leader %s, want %s", leaderID, first)
+	}
+	if third, leaderID := leader(t, m, "rome", release); leaderID != third {
+		t.Errorf("job for another key got leader %s, want itself", leaderID)
+	}
+
+	close(release)
+	released(t, m, first, "paris")
+	if fourth, leaderID := leader(t, m, "paris", release); leaderID != fourth {
+		t.Errorf("job after the leader finished got leader %s, want itself", leaderID)
+	}
+}
+
+func TestLeadAcrossInstances(t *testing.T) {
+	defer func(ttl, interval time.Duration) { leaseTTL, leaseRenewInterval = ttl, interval }(leaseTTL, leaseRenewInterval)
+	leaseTTL, leaseRenewInterval = 200*time.Millisecond, 50*time.Millisecond
+
+	store := database.NewMemoryStore()
+	a := NewManager(context.Background(), store)
+	a.SetLeaseStore(store)
+	b := NewManager(context.Background(), store)
+	b.SetLeaseStore(store)
+
+	release := make(chan struct{})
+	first, leaderID := leader(t, a, "paris", release)
+	if leaderID != first {
+		t.Fatalf("first job got leader %s, want itself", leaderID)
+	}
+
+	// The lease is renewed for as long as the job runs, past its TTL.
+	time.Sleep(3 * leaseTTL)
+	done := make(chan struct{})
+	close(done)
+	follower, leaderID := leader(t, b, "paris", done)
+	if leaderID != first {
+		t.Errorf("job on another instance got leader %s, want %s", leaderID, first)
+	}
+	released(t, b, follower, "paris")
+
+	// It is released when the job finishes.
+	close(release)
+	released(t, a, first, "paris")
+	other, leaderID := leader(t, b, "paris", done)
+	if leaderID != other {
+		t.Errorf("job after the leader finished got leader %s, want itself", leaderID)
+	}
+	released(t, b, other, "paris")
+}
+
+func TestLeaseOutlivesCrashedHolder(t *testing.T) {
+	store := database.NewMemoryStore()
+	// A holder that crashed never renews or releases its lease.
+	if _, err := store.AcquireLease(context.Background(), "paris", "crashed", 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(context.Background(), store)
+	m.SetLeaseStore(store)
+	done := make(chan struct{})
+	close(done)
+	follower, leaderID := leader(t, m, "paris", done)
+	if leaderID != "crashed" {
+		t.Errorf("got leader %s while the lease is valid, want crashed", leaderID)
+	}
+	released(t, m, follower, "paris")
+
+	time.Sleep(100 * time.Millisecond)
+	id, leaderID := leader(t, m, "paris", done)
+	if leaderID != id {
+		t.Errorf("got leader %s after the lease expired, want itself", leaderID)
+	}
+	released(t, m, id, "paris")
+}
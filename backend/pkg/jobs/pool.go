@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrPoolFull is returned by Pool.Submit when every worker is busy and the
+// queue is full.
+var ErrPoolFull = errors.New("worker pool is full")
+
+// Pool runs tasks on a fixed number of workers. Tasks run with a context
+// derived from the pool's (server-owned) context and bounded by the pool's
+// timeout, so they finish even if the request that submitted them goes away.
+type Pool struct {
+	ctx     context.Context
+	timeout time.Duration
+	tasks   chan func(ctx context.Context)
+}
+
+// NewPool starts workers goroutines. At most queue tasks wait for a free
+// worker; further submissions fail with ErrPoolFull.
+func NewPool(ctx context.Context, workers, queue int, timeout time.Duration) *Pool {
+	p := &Pool{
+		ctx:     ctx,
+		timeout: timeout,
+		tasks:   make(chan func(ctx context.Context), queue),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// NewVideoPool creates the pool used for video generation, configured by
+// VIDEO_WORKERS (default 4), VIDEO_QUEUE (default 16) and VIDEO_TIMEOUT
+// (default 10m).
+func NewVideoPool(ctx context.Context) (*Pool, error) {
+	workers, err := envInt("VIDEO_WORKERS", 4)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := envInt("VIDEO_QUEUE", 16)
+	if err != nil {
+		return nil, err
+	}
+	timeout := 10 * time.Minute
+	if v := os.Getenv("VIDEO_TIMEOUT"); v != "" {
+		timeout, err = time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid VIDEO_TIMEOUT %q", v)
+		}
+	}
+	if workers < 1 || queue < 0 {
+		return nil, fmt.Errorf("VIDEO_WORKERS must be at least 1 and VIDEO_QUEUE non-negative")
+	}
+
+	log.Printf("Video pool: %d workers, queue %d, timeout %s", workers, queue, timeout)
+	return NewPool(ctx, workers, queue, timeout), nil
+}
+
+// Submit queues task and returns a channel that is closed once it has run.
+func (p *Pool) Submit(task func(ctx context.Context)) (<-chan struct{}, error) {
+	done := make(chan struct{})
+	wrapped := func(ctx context.Context) {
+		defer close(done)
+		task(ctx)
+	}
+
+	select {
+	case p.tasks <- wrapped:
+		return done, nil
+	default:
+		return nil, ErrPoolFull
+	}
+}
+
+func (p *Pool) work() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case task := <-p.tasks:
+			p.run(task)
+		}
+	}
+}
+
+func (p *Pool) run(task func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Pool task panicked: %v", r)
+		}
+	}()
+	task(ctx)
+}
+
+func envInt(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolRunsTasks(t *testing.T) {
+	p := NewPool(context.Background(), 2, 4, time.Minute)
+
+	// Results written by a task are visible once done is closed.
+	results := make([]int, 4)
+	var dones []<-chan struct{}
+	for i := range results {
+		done, err := p.Submit(func(ctx context.Context) { results[i] = i + 1 })
+		if err != nil {
+			t.Fatalf("Submit %d: %v", i, err)
+		}
+		dones = append(dones, done)
+	}
+	for i, done := range dones {
+		<-done
+		if results[i] != i+1 {
+			t.Errorf("task %d wrote %d, want %d", i, results[i], i+1)
+		}
+	}
+}
+
+func TestPoolRecoversPanics(t *testing.T) {
+	p := NewPool(context.Background(), 1, 1, time.Minute)
+
+	err := errors.New("unset")
+	done, _ := p.Submit(func(ctx context.Context) {
+		err = errors.New("set before the panic")
+		panic("boom")
+	})
+	<-done
+	if err.Error() != "set before the panic" {
+		t.Errorf("err = %v after the panic, want the value set before it", err)
+	}
+
+	// The worker survives to run the next task.
+	ran := false
+	done, _ = p.Submit(func(ctx context.Context) { ran = true })
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task after a panic never ran")
+	}
+	if !ran {
+		t.Error("task after a panic didn't run")
+	}
+}
+
+func TestPoolFull(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewPool(ctx, 1, 1, time.Minute)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	running, err := p.Submit(func(ctx context.Context) {
+		close(started)
+		<-release
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	queued, err := p.Submit(func(ctx context.Context) {})
+	if err != nil {
+		t.Fatalf("Submit with room in the queue: %v", err)
+	}
+	if _, err := p.Submit(func(ctx context.Context) {}); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("Submit to a full pool: %v, want ErrPoolFull", err)
+	}
+
+	close(release)
+	<-running
+	<-queued
+	if _, err := p.Submit(func(ctx context.Context) {}); err != nil {
+		t.Errorf("Submit after the queue drained: %v", err)
+	}
+}
+
+func TestPoolTimeout(t *testing.T) {
+	p := NewPool(context.Background(), 1, 1, 10*time.Millisecond)
+
+	done, err := p.Submit(func(ctx context.Context) { <-ctx.Done() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task outlived the pool's timeout")
+	}
+}
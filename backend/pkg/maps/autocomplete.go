@@ -0,0 +1,43 @@
+package maps
+
+import (
+	"context"
+	"log"
+
+	"googlemaps.github.io/maps"
+)
+
+// Suggestion completes a partially typed city name.
+type Suggestion struct {
+	Text    string // e.g. "Paris, France"
+	PlaceID string
+}
+
+// Autocompleter suggests cities as the user types. Service is the Google
+// Maps implementation.
+type Autocompleter interface {
+	// Autocomplete returns cities whose names complete input, best first.
+	Autocomplete(ctx context.Context, input string) ([]Suggestion, error)
+}
+
+// Autocomplete asks Places Autocomplete for cities matching input. Requests
+// carry no session token: a picked suggestion is looked up with Geocoding
+// (see GetPlace), which can't close a Places session, and suggestions are
+// cached across users anyway. Each request is billed on its own.
+func (s *Service) Autocomplete(ctx context.Context, input string) ([]Suggestion, error) {
+	req := &maps.PlaceAutocompleteRequest{
+		Input: input,
+		Types: maps.AutocompletePlaceTypeCities,
+	}
+
+	r, err := s.client.PlaceAutocomplete(ctx, req)
+	if err != nil {
+		log.Printf("Autocomplete failed for '%s': %v", input, err)
+		return nil, err
+	}
+	suggestions := make([]Suggestion, len(r.Predictions))
+	for i, p := range r.Predictions {
+		suggestions[i] = Suggestion{Text: p.Description, PlaceID: p.PlaceID}
+	}
+	return suggestions, nil
+}
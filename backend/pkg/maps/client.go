@@ -2,13 +2,74 @@ package maps
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 
 	"googlemaps.github.io/maps"
 )
 
+// Geocoder resolves free-text city queries and coordinates into places.
+// Service is the Google Maps implementation.
+type Geocoder interface {
+	// FindCities returns the places a query may mean, best match first:
+	// several for ambiguous names like "Springfield".
+	FindCities(ctx context.Context, query string) ([]Place, error)
+	// GetPlace returns the place with the given ID, e.g. one of the
+	// candidates FindCities returned.
+	GetPlace(ctx context.Context, placeID string) (Place, error)
+	GetReverseGeocoding(ctx context.Context, lat, lng float64) (Place, error)
+}
+
+// ErrNotFound is returned (wrapped) by a Geocoder when nothing matches.
+// Other errors mean the lookup itself failed.
+var ErrNotFound = errors.New("not found")
+
+// Place is a resolved location.
+type Place struct {
+	// ID is the Maps place ID: the same place has the same ID however it was
+	// searched for, unlike Name.
+	ID      string
+	Name    string // Human-readable name, e.g. "Fort Collins, CO, USA"
+	Country string // Country name, e.g. "United States", if known
+	Lat     float64
+	Lng     float64
+}
+
+// localityTypes are the Maps result types that name a city or town.
+var localityTypes = []string{"locality", "postal_town"}
+
+// isLocality reports whether a Maps result names a city or town.
+func isLocality(r maps.GeocodingResult) bool {
+	return slices.ContainsFunc(r.Types, func(t string) bool {
+		return slices.Contains(localityTypes, t)
+	})
+}
+
+// countryOf returns the long country name of a Maps result.
+func countryOf(r maps.GeocodingResult) string {
+	for _, component := range r.AddressComponents {
+		if slices.Contains(component.Types, "country") {
+			return component.LongName
+		}
+	}
+	return ""
+}
+
+// toPlace converts a Maps result named by its formatted address.
+func toPlace(r maps.GeocodingResult) Place {
+	return Place{
+		ID:      r.PlaceID,
+		Name:    r.FormattedAddress,
+		Country: countryOf(r),
+		Lat:     r.Geometry.Location.Lat,
+		Lng:     r.Geometry.Location.Lng,
+	}
+}
+
 type Service struct {
 	client *maps.Client
 }
@@ -26,17 +87,20 @@ func NewService() (*Service, error) {
 	return &Service{client: c}, nil
 }
 
-func (s *Service) GetReverseGeocoding(ctx context.Context, lat, lng float64) (string, error) {
+// GetReverseGeocoding names the city at the given coordinates. The place ID
+// and coordinates are those of the city (locality), if Maps returns it, so
+// every point in a city resolves to the same place.
+func (s *Service) GetReverseGeocoding(ctx context.Context, lat, lng float64) (Place, error) {
 	log.Printf("Reverse geocoding lat: %f, lng: %f", lat, lng)
 	r, err := s.client.Geocode(ctx, &maps.GeocodingRequest{
 		LatLng: &maps.LatLng{Lat: lat, Lng: lng},
 	})
 	if err != nil {
 		log.Printf("Reverse geocoding failed: %v", err)
-		return "", err
+		return Place{}, err
 	}
 	if len(r) == 0 {
-		return "", fmt.Errorf("location not found")
+		return Place{}, fmt.Errorf("location %w", ErrNotFound)
 	}
 
 	// Extract city and state from address components of the first result
@@ -65,49 +129,73 @@ func (s *Service) GetReverseGeocoding(ctx context.Context, lat, lng float64) (st
 		}
 	}
 
-	// Fallback logic
-	if friendlyName == "" {
-		// Try to find a result with type 'locality' if components failed
-		for _, result := range r {
-			for _, t := range result.Types {
-				if t == "locality" {
-					friendlyName = result.FormattedAddress
-					break
-				}
-			}
-			if friendlyName != "" {
-				break
-			}
+	// The first result is usually a street address; the city is further down.
+	locality := r[0]
+	for _, result := range r {
+		if isLocality(result) {
+			locality = result
+			break
 		}
 	}
-	
+
+	// Fallback logic
 	if friendlyName == "" {
-		friendlyName = r[0].FormattedAddress
+		friendlyName = locality.FormattedAddress
 	}
-	
-	log.Printf("Reverse geocoding success: %s", friendlyName)
-	return friendlyName, nil
+
+	log.Printf("Reverse geocoding success: %s (%s)", friendlyName, locality.PlaceID)
+	place := toPlace(locality)
+	place.Name = friendlyName
+	return place, nil
 }
 
-func (s *Service) GetCityLocation(ctx context.Context, city string) (string, float64, float64, error) {
-	log.Printf("Geocoding city: %s", city)
+// FindCities geocodes a free-text query. If any of the results is a city or
+// town, only those are returned: a query for "Springfield" also matches
+// streets and businesses named after the cities.
+func (s *Service) FindCities(ctx context.Context, query string) ([]Place, error) {
+	log.Printf("Geocoding city: %s", query)
 	r, err := s.client.Geocode(ctx, &maps.GeocodingRequest{
-		Address: city,
+		Address: query,
 	})
 	if err != nil {
 		log.Printf("Geocoding failed: %v", err)
-		return "", 0, 0, err
+		return nil, err
 	}
 	if len(r) == 0 {
-		log.Printf("Geocoding found no results for: %s", city)
-		return "", 0, 0, fmt.Errorf("city not found")
+		log.Printf("Geocoding found no results for: %s", query)
+		return nil, fmt.Errorf("city %w", ErrNotFound)
+	}
+
+	if slices.ContainsFunc(r, isLocality) {
+		r = slices.DeleteFunc(r, func(result maps.GeocodingResult) bool { return !isLocality(result) })
+	}
+	var places []Place
+	for _, result := range r {
+		if !slices.ContainsFunc(places, func(p Place) bool { return p.ID == result.PlaceID }) {
+			places = append(places, toPlace(result))
+		}
 	}
+	log.Printf("Geocoding success: %s (Lat: %f, Lng: %f), %d candidates", places[0].Name, places[0].Lat, places[0].Lng, len(places))
 
-	formattedAddress := r[0].FormattedAddress
-	lat := r[0].Geometry.Location.Lat
-	lng := r[0].Geometry.Location.Lng
-	
-	log.Printf("Geocoding success: %s (Lat: %f, Lng: %f)", formattedAddress, lat, lng)
+	return places, nil
+}
 
-	return formattedAddress, lat, lng, nil
+// GetPlace looks up a place by its Maps place ID.
+func (s *Service) GetPlace(ctx context.Context, placeID string) (Place, error) {
+	log.Printf("Geocoding place: %s", placeID)
+	r, err := s.client.Geocode(ctx, &maps.GeocodingRequest{
+		PlaceID: placeID,
+	})
+	// Unknown or malformed IDs are rejected rather than found nothing for.
+	if err != nil && (strings.HasPrefix(err.Error(), "maps: NOT_FOUND") || strings.HasPrefix(err.Error(), "maps: INVALID_REQUEST")) {
+		return Place{}, fmt.Errorf("place %w", ErrNotFound)
+	}
+	if err != nil {
+		log.Printf("Geocoding place failed: %v", err)
+		return Place{}, err
+	}
+	if len(r) == 0 {
+		return Place{}, fmt.Errorf("place %w", ErrNotFound)
+	}
+	return toPlace(r[0]), nil
 }
@@ -0,0 +1,178 @@
+package presets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/genai"
+	"banana-weather/pkg/storage"
+)
+
+// Preset describes a gallery entry to generate.
+type Preset struct {
+	ID       string
+	Name     string
+	Category string
+	City     string // Query passed to the prompt
+	Context  string // Extra prompt context (e.g. for fictional places)
+}
+
+// Location returns the stored form of p, without media.
+func (p Preset) Location() database.Location {
+	return database.Location{
+		ID:            p.ID,
+		Name:          p.Name,
+		Category:      p.Category,
+		CityQuery:     p.City,
+		PromptContext: p.Context,
+		IsPreset:      true,
+	}
+}
+
+// Generator generates preset media (image + video) and saves the preset. It
+// is shared by cmd/generate_preset and the admin API.
+type Generator struct {
+	Images  genai.ImageGenerator
+	Videos  genai.VideoGenerator
+	Storage storage.BlobStore
+	DB      database.LocationStore
+}
+
+// Generate saves p, creating the preset if it doesn't exist, and generates
+// its media. Progress messages are passed to progress, which may be nil.
+func (g *Generator) Generate(ctx context.Context, p Preset, progress func(msg string)) (*database.Location, error) {
+	err := g.DB.UpdateLocation(ctx, database.PresetNamespace, p.ID, func(loc *database.Location) bool {
+		loc.Name, loc.Category, loc.CityQuery, loc.PromptContext = p.Name, p.Category, p.City, p.Context
+		loc.IsPreset = true
+		return true
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		err = g.DB.UpsertLocation(ctx, p.Location())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save: %w", err)
+	}
+	return g.GenerateLocation(ctx, p.Location(), progress)
+}
+
+// GenerateLocation generates new media for any stored location, keeping its
+// metadata. Like presets, the image is generated without a forecast, so the
+// model looks up the current weather itself.
+//
+// The image is saved with the pending Veo operation before the video is
+// awaited, so that the server can finish the video (see
+// api.Handler.ResumePendingVideos) if this process dies. Only media fields
+// are written.
+func (g *Generator) GenerateLocation(ctx context.Context, loc database.Location, progress func(msg string)) (*database.Location, error) {
+	report := func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		log.Printf("[%s] %s", loc.ID, msg)
+		if progress != nil {
+			progress(msg)
+		}
+	}
+
+	// 1. Generate Image
+	report("Generating image for '%s'...", loc.CityQuery)
+	imgBase64, err := g.Images.GenerateImage(ctx, loc.CityQuery, loc.PromptContext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("image gen failed: %w", err)
+	}
+
+	// 2. Upload Image
+	imgFileName := fmt.Sprintf("image_%d.png", time.Now().UnixNano())
+	if loc.IsPreset {
+		imgFileName = fmt.Sprintf("preset_%s_image_%d.png", loc.ID, time.Now().Unix())
+	}
+	gsImageURI, publicImageURL, err := g.Storage.UploadImage(ctx, imgBase64, imgFileName)
+	if err != nil {
+		return nil, fmt.Errorf("image upload failed: %w", err)
+	}
+	report("Image uploaded: %s", publicImageURL)
+
+	// 3. Start Video
+	report("Generating video (Veo)...")
+	opName, err := g.Videos.StartVideo(ctx, gsImageURI, genai.VideoPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("video gen failed: %w", err)
+	}
+
+	// 4. Save the image and the pending video
+	now := time.Now()
+	err = g.DB.UpdateLocation(ctx, loc.Namespace(), loc.ID, func(current *database.Location) bool {
+		current.ImageURL, current.VideoURL = publicImageURL, ""
+		current.Forecast, current.Fingerprint = nil, nil
+		current.VideoOpName, current.VideoOpStartedAt = opName, &now
+		current.LastUpdated = now
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save: %w", err)
+	}
+
+	// 5. Await Video
+	videoGsURI, err := g.Videos.AwaitVideo(ctx, opName)
+	if err != nil {
+		// Only forget the operation if Veo reported a failure; if we were
+		// stopped it is resumed by the server.
+		if ctx.Err() == nil {
+			g.saveVideo(ctx, loc, opName, "")
+		}
+		return nil, fmt.Errorf("video gen failed: %w", err)
+	}
+
+	publicVideoURL := g.Storage.PublicURL(videoGsURI)
+	report("Video generated: %s", publicVideoURL)
+
+	// 6. Save Video
+	saved, err := g.saveVideo(ctx, loc, opName, publicVideoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save: %w", err)
+	}
+	return saved, nil
+}
+
+// saveVideo stores videoURL (if any) on loc and clears its pending operation
+// opName, returning the saved location. It fails if the location has since
+// been regenerated with a different operation.
+func (g *Generator) saveVideo(ctx context.Context, loc database.Location, opName, videoURL string) (*database.Location, error) {
+	var saved database.Location
+	regenerated := false
+	err := g.DB.UpdateLocation(ctx, loc.Namespace(), loc.ID, func(current *database.Location) bool {
+		if current.VideoOpName != opName {
+			regenerated = true
+			return false
+		}
+		if videoURL != "" {
+			current.VideoURL = videoURL
+		}
+		current.VideoOpName, current.VideoOpStartedAt = "", nil
+		saved = *current
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if regenerated {
+		return nil, fmt.Errorf("location %s was regenerated meanwhile", loc.ID)
+	}
+	return &saved, nil
+}
+
+// PatchMetadata updates the name and category of an existing preset, keeping
+// its media.
+func (g *Generator) PatchMetadata(ctx context.Context, p Preset) error {
+	return g.DB.UpdateLocation(ctx, database.PresetNamespace, p.ID, func(loc *database.Location) bool {
+		loc.Name = p.Name
+		loc.Category = p.Category
+		loc.IsPreset = true
+		if p.Context != "" {
+			loc.PromptContext = p.Context
+		}
+		return true
+	})
+}
@@ -0,0 +1,116 @@
+package presets
+
+import (
+	"context"
+	"testing"
+
+	"banana-weather/pkg/database"
+	"banana-weather/pkg/genai"
+	"banana-weather/pkg/storage"
+)
+
+// awaitHook is a VideoGenerator that calls a hook before completing a
+// placeholder video.
+type awaitHook struct {
+	*genai.PlaceholderService
+	hook func(ctx context.Context, opName string) error
+}
+
+func (v awaitHook) AwaitVideo(ctx context.Context, opName string) (string, error) {
+	if err := v.hook(ctx, opName); err != nil {
+		return "", err
+	}
+	return v.PlaceholderService.AwaitVideo(ctx, opName)
+}
+
+func newGenerator(t *testing.T, hook func(ctx context.Context, opName string) error) *Generator {
+	t.Helper()
+	media, err := storage.NewLocalService(t.TempDir(), "http://media.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	placeholder := genai.NewPlaceholderService(media)
+	return &Generator{
+		Images:  placeholder,
+		Videos:  awaitHook{placeholder, hook},
+		Storage: media,
+		DB:      database.NewMemoryStore(),
+	}
+}
+
+func TestGenerateRecordsPendingVideo(t *testing.T) {
+	ctx := context.Background()
+	var g *Generator
+	g = newGenerator(t, func(ctx context.Context, opName string) error {
+		// The operation is persisted before it is awaited.
+		pending, err := g.DB.GetPendingVideos(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != 1 || pending[0].ID != "paris" || pending[0].VideoOpName != opName || pending[0].ImageURL == "" {
+			t.Errorf("pending videos while awaiting: %+v, want paris with %s and its image", pending, opName)
+		}
+		return nil
+	})
+
+	loc, err := g.Generate(ctx, Preset{ID: "paris", Name: "Paris", Category: "Europe", City: "Paris, France"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc.ImageURL == "" || loc.VideoURL == "" || loc.VideoOpName != "" || loc.VideoOpStartedAt != nil {
+		t.Errorf("generated %+v, want media and no pending operation", loc)
+	}
+	stored, err := g.DB.GetLocation(ctx, database.PresetNamespace, "paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Paris" || stored.VideoURL != loc.VideoURL || stored.VideoOpName != "" {
+		t.Errorf("stored %+v, want the generated preset", stored)
+	}
+}
+
+func TestGenerateLeavesInterruptedVideoPending(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := newGenerator(t, func(ctx context.Context, opName string) error {
+		cancel() // The process is stopping, e.g. for a deploy.
+		return ctx.Err()
+	})
+	g.DB.UpsertLocation(ctx, database.Location{ID: "rome", Name: "Rome", CityQuery: "Rome", VideoURL: "http://media.test/old.mp4", IsPreset: true})
+
+	if _, err := g.GenerateLocation(ctx, database.Location{ID: "rome", CityQuery: "Rome", IsPreset: true}, nil); err == nil {
+		t.Fatal("GenerateLocation succeeded after being canceled")
+	}
+	pending, err := g.DB.GetPendingVideos(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != "rome" || pending[0].Name != "Rome" || pending[0].VideoURL != "" {
+		t.Errorf("pending videos: %+v, want rome with its new image only", pending)
+	}
+}
+
+func TestGenerateLocationKeepsConcurrentEdits(t *testing.T) {
+	ctx := context.Background()
+	var g *Generator
+	g = newGenerator(t, func(ctx context.Context, opName string) error {
+		// An admin renames the preset while its video is generated.
+		return g.PatchMetadata(ctx, Preset{ID: "oslo", Name: "Oslo, Norway", Category: "Nordics"})
+	})
+	g.DB.UpsertLocation(ctx, database.Location{ID: "oslo", Name: "Oslo", Category: "Europe", CityQuery: "Oslo", IsPreset: true})
+
+	snapshot, _ := g.DB.GetLocation(ctx, database.PresetNamespace, "oslo")
+	if _, err := g.GenerateLocation(ctx, *snapshot, nil); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := g.DB.GetLocation(ctx, database.PresetNamespace, "oslo")
+	if stored.Name != "Oslo, Norway" || stored.Category != "Nordics" || stored.VideoURL == "" {
+		t.Errorf("stored %+v, want the edit and the new media", stored)
+	}
+}
+
+func TestGenerateLocationDeleted(t *testing.T) {
+	g := newGenerator(t, func(ctx context.Context, opName string) error { return nil })
+	if _, err := g.GenerateLocation(context.Background(), database.Location{ID: "gone", CityQuery: "Nowhere", IsPreset: true}, nil); err == nil {
+		t.Error("GenerateLocation of a missing location succeeded")
+	}
+}
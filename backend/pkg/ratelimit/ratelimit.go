@@ -0,0 +1,276 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"banana-weather/pkg/auth"
+	"banana-weather/pkg/database"
+)
+
+// Class is a separately budgeted kind of work.
+type Class string
+
+const (
+	Image Class = "image" // Gemini image generation
+	Video Class = "video" // Veo video generation
+	Cache Class = "cache" // Requests served from the location cache
+
+	Geocode      Class = "geocode"      // Place searches, which may call the Maps API
+	Autocomplete Class = "autocomplete" // Maps autocomplete calls
+)
+
+// Budget allows Limit uses per Window. A zero Limit means unlimited.
+type Budget struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Limiter enforces per-client budgets. Counters live in a CounterStore, so
+// limits can be shared across instances (Firestore) or kept per process.
+type Limiter struct {
+	store   database.CounterStore
+	budgets map[Class]Budget
+
+	// KeyFunc identifies the client of a request. It defaults to
+	// l.ClientKey.
+	KeyFunc func(r *http.Request) string
+
+	// TrustedProxies are the proxies whose X-Forwarded-For header is
+	// believed (see ClientIP). None by default.
+	TrustedProxies []*net.IPNet
+}
+
+func NewLimiter(store database.CounterStore, budgets map[Class]Budget) *Limiter {
+	l := &Limiter{
+		store:   store,
+		budgets: budgets,
+	}
+	l.KeyFunc = l.ClientKey
+	return l
+}
+
+// NewLimiterFromEnv creates a Limiter with budgets from RATE_LIMIT_IMAGE
+// (default 20/1h), RATE_LIMIT_VIDEO (default 10/1h), RATE_LIMIT_CACHE
+// (default 300/1h), RATE_LIMIT_GEOCODE (default 100/1h) and
+// RATE_LIMIT_AUTOCOMPLETE (default 300/1h). Each is "<limit>/<window>", e.g. "5/10m"; "0" disables
+// the limit. RATE_LIMIT_TRUSTED_PROXIES lists the proxies in front of the
+// server (see ParseProxies); without it clients are told apart by the
+// address they connect from.
+func NewLimiterFromEnv(store database.CounterStore) (*Limiter, error) {
+	defaults := map[Class]string{
+		Image:        "20/1h",
+		Video:        "10/1h",
+		Cache:        "300/1h",
+		Geocode:      "100/1h",
+		Autocomplete: "300/1h",
+	}
+	budgets := make(map[Class]Budget)
+	for class, def := range defaults {
+		name := "RATE_LIMIT_" + strings.ToUpper(string(class))
+		v := os.Getenv(name)
+		if v == "" {
+			v = def
+		}
+		b, err := ParseBudget(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		budgets[class] = b
+	}
+
+	proxies, err := ParseProxies(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_TRUSTED_PROXIES: %w", err)
+	}
+
+	log.Printf("Rate limits: image %s, video %s, cache %s, geocode %s, autocomplete %s",
+		budgets[Image], budgets[Video], budgets[Cache], budgets[Geocode], budgets[Autocomplete])
+	l := NewLimiter(store, budgets)
+	l.TrustedProxies = proxies
+	return l, nil
+}
+
+// ParseProxies parses a comma-separated list of IP addresses and CIDR
+// ranges, e.g. "10.0.0.1,169.254.0.0/16".
+func ParseProxies(s string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("bad address %q", p)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, ipNet)
+	}
+	return proxies, nil
+}
+
+// ParseBudget parses "<limit>/<window>" (e.g. "20/1h") or "0".
+func ParseBudget(s string) (Budget, error) {
+	if s == "0" {
+		return Budget{}, nil
+	}
+	limitStr, windowStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return Budget{}, fmt.Errorf("%q is not <limit>/<window>", s)
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 0 {
+		return Budget{}, fmt.Errorf("invalid limit %q", limitStr)
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return Budget{}, fmt.Errorf("invalid window %q", windowStr)
+	}
+	return Budget{Limit: limit, Window: window}, nil
+}
+
+func (b Budget) String() string {
+	if b.Limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d/%s", b.Limit, b.Window)
+}
+
+// LimitError is returned by Quota.Take when a budget is exhausted.
+type LimitError struct {
+	Class      Class
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit reached, retry after %s", e.Class, e.RetryAfter)
+}
+
+// Quota is the remaining budget of one client. Its zero value (and nil) allow
+// everything.
+type Quota struct {
+	l      *Limiter
+	client string
+}
+
+// For returns the Quota of client.
+func (l *Limiter) For(client string) *Quota {
+	return &Quota{l: l, client: client}
+}
+
+// Take uses one unit of class. It returns a *LimitError if the budget is
+// exhausted. Store failures are logged and allowed, so an outage of the
+// counter store does not take the site down.
+func (q *Quota) Take(ctx context.Context, class Class) error {
+	if q == nil || q.l == nil {
+		return nil
+	}
+	b := q.l.budgets[class]
+	if b.Limit == 0 {
+		return nil
+	}
+
+	counter, ok, err := q.l.store.IncrementCounter(ctx, counterKey(class, q.client), b.Limit, b.Window)
+	if err != nil {
+		log.Printf("Rate limit check failed for %s: %v", q.client, err)
+		return nil
+	}
+	if !ok {
+		retryAfter := time.Until(counter.ResetAt).Round(time.Second)
+		log.Printf("Rate limit: %s exceeded %s budget (%s)", q.client, class, b)
+		return &LimitError{Class: class, RetryAfter: max(retryAfter, time.Second)}
+	}
+	return nil
+}
+
+// counterKey builds a counter key that is also a valid Firestore document ID.
+func counterKey(class Class, client string) string {
+	return string(class) + "_" + strings.ReplaceAll(client, "/", "_")
+}
+
+type contextKey struct{}
+
+// Middleware attaches the client's Quota to the request context. Budgets are
+// charged later, once the handler knows what the request costs (a cache hit,
+// an image or a video), with FromContext(ctx).Take.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := l.For(l.KeyFunc(r))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, q)))
+	})
+}
+
+// FromContext returns the Quota attached by Middleware, or nil.
+func FromContext(ctx context.Context) *Quota {
+	q, _ := ctx.Value(contextKey{}).(*Quota)
+	return q
+}
+
+// ClientKey identifies a client by its API key (see auth.Middleware), falling
+// back to its IP address for anonymous requests.
+func (l *Limiter) ClientKey(r *http.Request) string {
+	if key := auth.FromContext(r.Context()); key != nil {
+		return "key:" + key.ID
+	}
+	return ClientIP(r, l.TrustedProxies)
+}
+
+// ClientIP identifies a client by IP address: the address the request came
+// from, unless that is one of the trusted proxies. Then X-Forwarded-For is
+// read from the end, skipping the trusted proxies it passed through; entries
+// before the first untrusted one are supplied by the client and are ignored.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(host, trusted) {
+		return "ip:" + host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break // Malformed: nothing before it can be believed either
+		}
+		host = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return "ip:" + host
+}
+
+// isTrusted reports whether addr is in one of the trusted ranges.
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
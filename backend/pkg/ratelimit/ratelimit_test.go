@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"banana-weather/pkg/auth"
+	"banana-weather/pkg/database"
+)
+
+func TestParseBudget(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Budget
+	}{
+		{"20/1h", Budget{Limit: 20, Window: time.Hour}},
+		{"5/10m", Budget{Limit: 5, Window: 10 * time.Minute}},
+		{"0", Budget{}},
+	}
+	for _, tt := range tests {
+		if got, err := ParseBudget(tt.s); err != nil || got != tt.want {
+			t.Errorf("ParseBudget(%q) = %v, %v; want %v", tt.s, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{"", "20", "x/1h", "-1/1h", "20/x", "20/0s", "20/-1h"} {
+		if _, err := ParseBudget(s); err == nil {
+			t.Errorf("ParseBudget(%q) succeeded", s)
+		}
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	proxies, err := ParseProxies(" 10.0.0.1, 169.254.0.0/16,,::1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range proxies {
+		got = append(got, p.String())
+	}
+	want := []string{"10.0.0.1/32", "169.254.0.0/16", "::1/128"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseProxies = %v, want %v", got, want)
+	}
+
+	if proxies, err := ParseProxies(""); err != nil || len(proxies) != 0 {
+		t.Errorf("ParseProxies(\"\") = %v, %v; want none", proxies, err)
+	}
+	for _, s := range []string{"10.0.0", "10.0.0.0/33", "proxy.internal"} {
+		if _, err := ParseProxies(s); err == nil {
+			t.Errorf("ParseProxies(%q) succeeded", s)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseProxies("10.0.0.1,169.254.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		remote, xff string
+		trusted     bool
+		want        string
+	}{
+		{"direct", "203.0.113.7:1234", "", true, "ip:203.0.113.7"},
+		{"no trusted proxies", "10.0.0.1:1234", "198.51.100.1", false, "ip:10.0.0.1"},
+		{"spoofed header", "203.0.113.7:1234", "198.51.100.1", true, "ip:203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:1234", "198.51.100.1", true, "ip:198.51.100.1"},
+		{"client-supplied entries", "10.0.0.1:1234", "192.0.2.1, 198.51.100.1", true, "ip:198.51.100.1"},
+		{"chain of proxies", "10.0.0.1:1234", "198.51.100.1, 169.254.1.1", true, "ip:198.51.100.1"},
+		{"only proxies", "10.0.0.1:1234", "169.254.1.1", true, "ip:169.254.1.1"},
+		{"malformed hop", "10.0.0.1:1234", "198.51.100.1, garbage", true, "ip:10.0.0.1"},
+		{"no header", "10.0.0.1:1234", "", true, "ip:10.0.0.1"},
+		{"no port", "203.0.113.7", "", true, "ip:203.0.113.7"},
+		{"IPv6", "[2001:db8::1]:1234", "", true, "ip:2001:db8::1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/weather", nil)
+		r.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			r.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		var proxies []*net.IPNet
+		if tt.trusted {
+			proxies = trusted
+		}
+		if got := ClientIP(r, proxies); got != tt.want {
+			t.Errorf("%s: ClientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	store := database.NewMemoryStore()
+	raw, key := auth.NewKey("partner")
+	store.UpsertAPIKey(context.Background(), key)
+
+	l := NewLimiter(store, nil)
+	var got []string
+	handler := auth.NewAuthenticator(store, true).Middleware(l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, FromContext(r.Context()).client)
+	})))
+	for _, header := range []string{"", raw} {
+		r := httptest.NewRequest("GET", "/api/weather", nil)
+		r.RemoteAddr = "203.0.113.7:1234"
+		r.Header.Set("X-API-Key", header)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if len(got) != 2 || got[0] != "ip:203.0.113.7" || got[1] != "key:"+key.ID {
+		t.Errorf("clients %q, want the IP address, then the key", got)
+	}
+}
+
+// failingCounters is a CounterStore that is down.
+type failingCounters struct{}
+
+func (failingCounters) IncrementCounter(ctx context.Context, key string, limit int, window time.Duration) (database.Counter, bool, error) {
+	return database.Counter{}, false, errors.New("unavailable")
+}
+
+func TestTake(t *testing.T) {
+	ctx := context.Background()
+	l := NewLimiter(database.NewMemoryStore(), map[Class]Budget{
+		Image: {Limit: 2, Window: time.Hour},
+	})
+
+	alice := l.For("ip:203.0.113.7")
+	for i := 0; i < 2; i++ {
+		if err := alice.Take(ctx, Image); err != nil {
+			t.Fatalf("take %d: %v", i+1, err)
+		}
+	}
+	var limitErr *LimitError
+	if err := alice.Take(ctx, Image); !errors.As(err, &limitErr) || limitErr.Class != Image || limitErr.RetryAfter <= 0 || limitErr.RetryAfter > time.Hour {
+		t.Errorf("take over the budget: %v, want a LimitError", err)
+	}
+	// Other classes and clients have their own budgets.
+	if err := alice.Take(ctx, Video); err != nil {
+		t.Errorf("unlimited class: %v", err)
+	}
+	if err := l.For("ip:2001:db8::/64").Take(ctx, Image); err != nil {
+		t.Errorf("other client: %v", err)
+	}
+
+	// No quota and a failing store allow everything.
+	var none *Quota
+	if err := none.Take(ctx, Image); err != nil {
+		t.Errorf("nil Quota: %v", err)
+	}
+	down := NewLimiter(failingCounters{}, map[Class]Budget{Image: {Limit: 1, Window: time.Hour}})
+	if err := down.For("ip:203.0.113.7").Take(ctx, Image); err != nil {
+		t.Errorf("store down: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package slug
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLen caps the length of a slug in runes, leaving room for a hash suffix
+// well within Firestore's document ID limit.
+const MaxLen = 64
+
+// transliterations spells out letters that don't decompose into an ASCII
+// base letter: Cyrillic, Greek and a few Latin letters of their own.
+var transliterations = map[rune]string{
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ı': "i", 'ŋ': "ng",
+
+	// Cyrillic (Russian, Ukrainian, Belarusian, Serbian, Bulgarian...)
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u", 'ђ': "dj", 'ј': "j",
+	'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// Make returns a lowercase slug of s: words joined by underscores, accents
+// removed and Cyrillic and Greek transliterated, e.g. "São Paulo, Brasil" ->
+// "sao_paulo_brasil" and "Москва, Россия" -> "moskva_rossiya". Letters of
+// other scripts (e.g. Han, Arabic) are kept as they are, since there is no
+// single way to spell them in Latin letters: "東京都, 日本" -> "東京都_日本".
+func Make(s string) string {
+	var b strings.Builder
+	var n int
+	pendingSep := false
+	for _, r := range strings.ToLower(s) {
+		part, ok := transliterations[r]
+		if !ok {
+			part = baseLetters(r)
+		}
+		if part == "" {
+			pendingSep = pendingSep || (n > 0 && !ok)
+			continue
+		}
+
+		if pendingSep {
+			b.WriteByte('_')
+			n++
+			pendingSep = false
+		}
+		for _, pr := range part {
+			if n >= MaxLen {
+				return strings.TrimSuffix(b.String(), "_")
+			}
+			b.WriteRune(pr)
+			n++
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// baseLetters returns the letters and digits r decomposes into without its
+// accents, transliterated (e.g. "e" for 'é', "i" for 'ή', "fi" for 'ﬁ'), or
+// "" for anything else.
+func baseLetters(r rune) string {
+	var b strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		if t, ok := transliterations[d]; ok {
+			b.WriteString(t)
+		} else if unicode.IsLetter(d) || unicode.IsDigit(d) {
+			b.WriteRune(d)
+		}
+	}
+	return b.String()
+}
+
+// Hash returns the first n hex digits of the SHA-256 of key.
+func Hash(key string, n int) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:n]
+}
@@ -0,0 +1,42 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"San Francisco", "san_francisco"},
+		{"São Paulo, Brasil", "sao_paulo_brasil"},
+		{"Москва, Россия", "moskva_rossiya"},
+		{"Αθήνα, Ελλάδα", "athina_ellada"},
+		{"東京都, 日本", "東京都_日本"},
+		{"Straße", "strasse"},
+		{"  Washington, D.C.  ", "washington_d_c"},
+		{"St. John's", "st_john_s"},
+		{"Bar-le-Duc (Meuse)", "bar_le_duc_meuse"},
+		{"Sector 7", "sector_7"},
+		{"Ъ", ""},
+		{"", ""},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		if got := Make(tt.in); got != tt.want {
+			t.Errorf("Make(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMakeMaxLen(t *testing.T) {
+	got := Make(strings.Repeat("ab ", 100))
+	if n := utf8.RuneCountInString(got); n > MaxLen {
+		t.Errorf("Make of a long name has %d runes, want at most %d", n, MaxLen)
+	}
+	if strings.HasSuffix(got, "_") {
+		t.Errorf("Make of a long name = %q, ends in a separator", got)
+	}
+}
@@ -7,10 +7,53 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 
 	"cloud.google.com/go/storage"
 )
 
+// BlobStore stores generated media and exposes it via public URLs.
+// Service is the Google Cloud Storage implementation.
+type BlobStore interface {
+	ReadObject(ctx context.Context, fileName string) ([]byte, error)
+	UploadImage(ctx context.Context, imageBase64 string, fileName string) (string, string, error)
+	UploadBytes(ctx context.Context, data []byte, fileName string, mimeType string) (string, error)
+	// PublicURL converts a store-internal URI (as returned by UploadImage or
+	// written by Veo) into a URL the frontend can load.
+	PublicURL(uri string) string
+}
+
+// NewBlobStore returns the BlobStore selected by STORAGE_BACKEND:
+//   - "gcs" (default): Google Cloud Storage bucket GENMEDIA_BUCKET.
+//   - "local": files under MEDIA_DIR (default "media"), served from
+//     MEDIA_BASE_URL (default "/media").
+func NewBlobStore(ctx context.Context) (BlobStore, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "gcs":
+		s, err := NewService(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		baseURL := os.Getenv("MEDIA_BASE_URL")
+		if baseURL == "" {
+			baseURL = "/media"
+		}
+		s, err := NewLocalService(dir, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (want gcs or local)", backend)
+	}
+}
+
 type Service struct {
 	client     *storage.Client
 	bucketName string
@@ -105,3 +148,11 @@ func (s *Service) UploadBytes(ctx context.Context, data []byte, fileName string,
 	log.Printf("Uploaded %d bytes to %s", len(data), publicURL)
 	return publicURL, nil
 }
+
+// PublicURL converts gs://bucket/path to https://storage.googleapis.com/bucket/path.
+func (s *Service) PublicURL(uri string) string {
+	if path, ok := strings.CutPrefix(uri, "gs://"); ok {
+		return "https://storage.googleapis.com/" + path
+	}
+	return uri
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalService is a BlobStore that writes objects to a directory on disk.
+// It also implements http.Handler so the same directory can be served under
+// baseURL (see the /media route in main.go).
+type LocalService struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalService(dir string, baseURL string) (*LocalService, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage dir: %w", err)
+	}
+
+	log.Printf("Local Storage initialized at %s (served from %s)", absDir, baseURL)
+	return &LocalService{
+		dir:     absDir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// ReadObject reads the content of a file from the storage directory.
+func (s *LocalService) ReadObject(ctx context.Context, fileName string) ([]byte, error) {
+	path, err := s.path(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// UploadImage writes a base64 image to disk and returns (fileURI, publicURL).
+func (s *LocalService) UploadImage(ctx context.Context, imageBase64 string, fileName string) (string, string, error) {
+	data, err := base64.StdEncoding.DecodeString(imageBase64)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid base64: %w", err)
+	}
+	path, err := s.write(data, fileName)
+	if err != nil {
+		return "", "", err
+	}
+	return "file://" + filepath.ToSlash(path), s.objectURL(fileName), nil
+}
+
+// UploadBytes writes raw bytes to disk and returns the public URL.
+func (s *LocalService) UploadBytes(ctx context.Context, data []byte, fileName string, mimeType string) (string, error) {
+	if _, err := s.write(data, fileName); err != nil {
+		return "", err
+	}
+	publicURL := s.objectURL(fileName)
+	log.Printf("Stored %d bytes at %s", len(data), publicURL)
+	return publicURL, nil
+}
+
+// PublicURL converts a file:// URI inside the storage directory to its
+// public URL. Other URIs are returned unchanged.
+func (s *LocalService) PublicURL(uri string) string {
+	path, ok := strings.CutPrefix(uri, "file://")
+	if !ok {
+		return uri
+	}
+	rel, err := filepath.Rel(s.dir, filepath.FromSlash(path))
+	if err != nil || !filepath.IsLocal(rel) {
+		return uri
+	}
+	return s.objectURL(filepath.ToSlash(rel))
+}
+
+// ServeHTTP serves a stored object. The request path, with the mount prefix
+// already stripped, is the object name. Directories are never listed.
+func (s *LocalService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, err := s.path(strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Mirror the bucket CORS policy (cors.json) so the web player can load media.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (s *LocalService) write(data []byte, fileName string) (string, error) {
+	path, err := s.path(fileName)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	return path, nil
+}
+
+// path maps an object name to a path inside the storage directory,
+// rejecting names that would escape it.
+func (s *LocalService) path(fileName string) (string, error) {
+	name := filepath.FromSlash(fileName)
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid object name: %q", fileName)
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+func (s *LocalService) objectURL(fileName string) string {
+	return s.baseURL + "/" + fileName
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newLocalService(t *testing.T) (*LocalService, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := NewLocalService(dir, "http://media.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+func TestLocalUpload(t *testing.T) {
+	ctx := context.Background()
+	s, dir := newLocalService(t)
+
+	uri, publicURL, err := s.UploadImage(ctx, base64.StdEncoding.EncodeToString([]byte("png")), "image_paris.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publicURL != "http://media.test/image_paris.png" {
+		t.Errorf("public URL %q", publicURL)
+	}
+	if got := s.PublicURL(uri); got != publicURL {
+		t.Errorf("PublicURL(%q) = %q, want %q", uri, got, publicURL)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "image_paris.png")); err != nil || string(data) != "png" {
+		t.Errorf("file holds %q, %v", data, err)
+	}
+	if _, _, err := s.UploadImage(ctx, "not base64!", "image_bad.png"); err == nil {
+		t.Error("UploadImage accepted invalid base64")
+	}
+
+	// Names with slashes create directories.
+	publicURL, err = s.UploadBytes(ctx, []byte("mp4"), "videos/paris.mp4", "video/mp4")
+	if err != nil || publicURL != "http://media.test/videos/paris.mp4" {
+		t.Errorf("UploadBytes = %q, %v", publicURL, err)
+	}
+	if data, err := s.ReadObject(ctx, "videos/paris.mp4"); err != nil || string(data) != "mp4" {
+		t.Errorf("ReadObject = %q, %v", data, err)
+	}
+	if _, err := s.ReadObject(ctx, "videos/rome.mp4"); !IsNotExist(err) {
+		t.Errorf("ReadObject of a missing object: %v, want not exist", err)
+	}
+}
+
+func TestLocalRejectsEscapes(t *testing.T) {
+	ctx := context.Background()
+	s, dir := newLocalService(t)
+	for _, name := range []string{"../escape.png", "/etc/passwd", "videos/../../escape.png", ""} {
+		if _, err := s.UploadBytes(ctx, []byte("x"), name, ""); err == nil {
+			t.Errorf("UploadBytes(%q) succeeded", name)
+		}
+		if _, err := s.ReadObject(ctx, name); err == nil {
+			t.Errorf("ReadObject(%q) succeeded", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.png")); err == nil {
+		t.Error("a file was written outside the storage directory")
+	}
+
+	for _, uri := range []string{
+		"file:///etc/passwd",
+		"file://" + filepath.ToSlash(filepath.Join(dir, "..", "escape.png")),
+		"gs://bucket/videos/paris.mp4",
+	} {
+		if got := s.PublicURL(uri); got != uri {
+			t.Errorf("PublicURL(%q) = %q, want it unchanged", uri, got)
+		}
+	}
+}
+
+func TestLocalServeHTTP(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newLocalService(t)
+	s.UploadBytes(ctx, []byte("mp4 data"), "videos/paris.mp4", "video/mp4")
+	srv := httptest.NewServer(http.StripPrefix("/media", s))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/media/videos/paris.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "mp4 data" || resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("GET video: %d %q, headers %v", resp.StatusCode, body, resp.Header)
+	}
+
+	// Range requests let the browser seek in videos.
+	req, _ := http.NewRequest("GET", srv.URL+"/media/videos/paris.mp4", nil)
+	req.Header.Set("Range", "bytes=4-")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "data" {
+		t.Errorf("GET range: %d %q", resp.StatusCode, body)
+	}
+
+	for _, path := range []string{"/media/videos/", "/media/videos", "/media/rome.png", "/media/../local_test.go"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// ArchivePrefix is where ArchiveObject moves objects to. Pair it with a
+// bucket lifecycle rule to expire (or cold-store) archived media.
+const ArchivePrefix = "archive/"
+
+// Object describes a stored object.
+type Object struct {
+	Name    string
+	Size    int64
+	Created time.Time
+}
+
+// ObjectStore is a BlobStore whose objects can be listed and removed, for
+// garbage collection (see pkg/gc). Service and LocalService implement it.
+type ObjectStore interface {
+	BlobStore
+	// ListObjects returns every object whose name starts with prefix.
+	ListObjects(ctx context.Context, prefix string) ([]Object, error)
+	DeleteObject(ctx context.Context, name string) error
+	// ArchiveObject moves an object under ArchivePrefix.
+	ArchiveObject(ctx context.Context, name string) error
+	// ObjectName returns the object a public URL (see PublicURL) points to,
+	// or false if the URL is not in this store.
+	ObjectName(publicURL string) (string, bool)
+}
+
+// ListObjects lists the objects in the bucket that start with prefix.
+func (s *Service) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Size", "Created"}); err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	it := s.client.Bucket(s.bucketName).Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list gs://%s/%s: %w", s.bucketName, prefix, err)
+		}
+		objects = append(objects, Object{Name: attrs.Name, Size: attrs.Size, Created: attrs.Created})
+	}
+	return objects, nil
+}
+
+// DeleteObject deletes an object from the bucket.
+func (s *Service) DeleteObject(ctx context.Context, name string) error {
+	return s.client.Bucket(s.bucketName).Object(name).Delete(ctx)
+}
+
+// ArchiveObject copies an object under ArchivePrefix, then deletes it.
+func (s *Service) ArchiveObject(ctx context.Context, name string) error {
+	bucket := s.client.Bucket(s.bucketName)
+	src := bucket.Object(name)
+	if _, err := bucket.Object(ArchivePrefix + name).CopierFrom(src).Run(ctx); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", name, err)
+	}
+	return src.Delete(ctx)
+}
+
+// ObjectName maps https://storage.googleapis.com/<bucket>/<name> (or a gs://
+// URI) in this bucket to <name>.
+func (s *Service) ObjectName(publicURL string) (string, bool) {
+	for _, prefix := range []string{
+		"https://storage.googleapis.com/" + s.bucketName + "/",
+		"gs://" + s.bucketName + "/",
+	} {
+		if name, ok := strings.CutPrefix(publicURL, prefix); ok && name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ListObjects walks the storage directory for files whose object name starts
+// with prefix. Files are reported as created at their modification time.
+func (s *LocalService) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Name: name, Size: info.Size(), Created: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// DeleteObject removes a file from the storage directory.
+func (s *LocalService) DeleteObject(ctx context.Context, name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// ArchiveObject moves a file under ArchivePrefix.
+func (s *LocalService) ArchiveObject(ctx context.Context, name string) error {
+	src, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	dst, err := s.path(ArchivePrefix + name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return os.Rename(src, dst)
+}
+
+// ObjectName maps a URL under the base URL to its object name.
+func (s *LocalService) ObjectName(publicURL string) (string, bool) {
+	name, ok := strings.CutPrefix(publicURL, s.baseURL+"/")
+	if !ok || name == "" {
+		return "", false
+	}
+	if _, err := s.path(name); err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// IsNotExist reports whether err means an object does not exist, in either
+// store.
+func IsNotExist(err error) bool {
+	return errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, fs.ErrNotExist)
+}
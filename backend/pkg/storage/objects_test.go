@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalObjects(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newLocalService(t)
+	for _, name := range []string{"image_paris.png", "videos/paris.mp4", "videos/rome.mp4", "presets.json"} {
+		s.UploadBytes(ctx, []byte(name), name, "")
+	}
+
+	videos, err := s.ListObjects(ctx, "videos/")
+	if err != nil || len(videos) != 2 || videos[0].Name != "videos/paris.mp4" || videos[0].Size != int64(len("videos/paris.mp4")) || videos[0].Created.IsZero() {
+		t.Errorf("ListObjects(videos/) = %+v, %v", videos, err)
+	}
+
+	if err := s.ArchiveObject(ctx, "videos/paris.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.ReadObject(ctx, ArchivePrefix+"videos/paris.mp4"); err != nil || string(data) != "videos/paris.mp4" {
+		t.Errorf("archived object = %q, %v", data, err)
+	}
+	if err := s.DeleteObject(ctx, "videos/rome.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteObject(ctx, "videos/rome.mp4"); !IsNotExist(err) {
+		t.Errorf("deleting a missing object: %v, want not exist", err)
+	}
+	if videos, _ := s.ListObjects(ctx, "videos/"); len(videos) != 0 {
+		t.Errorf("videos left: %+v", videos)
+	}
+
+	for url, want := range map[string]string{
+		"http://media.test/image_paris.png":      "image_paris.png",
+		"http://media.test/videos/paris.mp4":     "videos/paris.mp4",
+		"http://media.test/":                     "",
+		"http://media.test/../escape.png":        "",
+		"https://storage.googleapis.com/b/x.mp4": "",
+	} {
+		name, ok := s.ObjectName(url)
+		if name != want || ok != (want != "") {
+			t.Errorf("ObjectName(%q) = %q, %v; want %q", url, name, ok, want)
+		}
+	}
+}
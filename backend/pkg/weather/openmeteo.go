@@ -0,0 +1,102 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const defaultOpenMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+// OpenMeteo fetches forecasts from the Open-Meteo API (no API key required).
+type OpenMeteo struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOpenMeteo creates a client for the given endpoint, or the public
+// Open-Meteo API if baseURL is empty.
+func NewOpenMeteo(baseURL string) *OpenMeteo {
+	if baseURL == "" {
+		baseURL = defaultOpenMeteoURL
+	}
+	return &OpenMeteo{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type openMeteoResponse struct {
+	Timezone string `json:"timezone"`
+	Current  struct {
+		Temperature float64 `json:"temperature_2m"`
+		WeatherCode int     `json:"weather_code"`
+	} `json:"current"`
+	Daily struct {
+		Time                     []string  `json:"time"`
+		TemperatureMax           []float64 `json:"temperature_2m_max"`
+		TemperatureMin           []float64 `json:"temperature_2m_min"`
+		WeatherCode              []int     `json:"weather_code"`
+		PrecipitationSum         []float64 `json:"precipitation_sum"`
+		PrecipitationProbability []int     `json:"precipitation_probability_max"`
+	} `json:"daily"`
+}
+
+func (o *OpenMeteo) GetForecast(ctx context.Context, lat, lng float64) (*Forecast, error) {
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("longitude", strconv.FormatFloat(lng, 'f', 4, 64))
+	q.Set("current", "temperature_2m,weather_code")
+	q.Set("daily", "temperature_2m_max,temperature_2m_min,weather_code,precipitation_sum,precipitation_probability_max")
+	q.Set("timezone", "auto")
+	q.Set("forecast_days", "1")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Fetching forecast for lat: %f, lng: %f", lat, lng)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("weather request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("weather API returned %s", resp.Status)
+	}
+
+	var data openMeteoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode weather response: %w", err)
+	}
+
+	d := data.Daily
+	if len(d.Time) == 0 || len(d.TemperatureMax) == 0 || len(d.TemperatureMin) == 0 || len(d.WeatherCode) == 0 {
+		return nil, fmt.Errorf("weather response has no daily forecast")
+	}
+
+	f := &Forecast{
+		Date:          d.Time[0],
+		Timezone:      data.Timezone,
+		Temperature:   data.Current.Temperature,
+		High:          d.TemperatureMax[0],
+		Low:           d.TemperatureMin[0],
+		ConditionCode: d.WeatherCode[0],
+		Condition:     ConditionName(d.WeatherCode[0]),
+		FetchedAt:     time.Now(),
+	}
+	if len(d.PrecipitationSum) > 0 {
+		f.Precipitation = d.PrecipitationSum[0]
+	}
+	if len(d.PrecipitationProbability) > 0 {
+		f.PrecipitationProbability = d.PrecipitationProbability[0]
+	}
+	return f, nil
+}
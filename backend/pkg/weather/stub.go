@@ -0,0 +1,72 @@
+package weather
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"banana-weather/pkg/gazetteer"
+)
+
+// Stub is an offline Provider returning plausible, deterministic weather:
+// the same coordinates on the same local day always produce the same
+// forecast.
+type Stub struct {
+	zones *gazetteer.Service
+}
+
+// NewStub creates a Stub that estimates the timezone of a place with zones
+// (see gazetteer.Service.TimezoneAt), or from its longitude alone if zones is
+// nil.
+func NewStub(zones *gazetteer.Service) *Stub {
+	return &Stub{zones: zones}
+}
+
+var stubConditions = []int{0, 1, 2, 3, 45, 61, 63, 71, 80, 95}
+
+func (s *Stub) GetForecast(ctx context.Context, lat, lng float64) (*Forecast, error) {
+	timezone := s.zones.TimezoneAt(lat, lng)
+	zone, err := time.LoadLocation(timezone)
+	if err != nil {
+		timezone, zone = "", time.UTC
+	}
+	now := time.Now().In(zone)
+	date := now.Format("2006-01-02")
+
+	h := fnv.New32a()
+	h.Write([]byte(date))
+	h.Write([]byte{byte(int(math.Round(lat))), byte(int(math.Round(lng)))})
+	seed := h.Sum32()
+
+	// Warmer towards the equator, with a seasonal swing that flips between hemispheres.
+	season := math.Cos(2 * math.Pi * float64(now.YearDay()-196) / 365)
+	if lat < 0 {
+		season = -season
+	}
+	mean := 27 - 0.4*math.Abs(lat) + 8*season + float64(seed%7) - 3
+	spread := 3 + float64(seed%5)
+
+	code := stubConditions[int(seed>>8)%len(stubConditions)]
+	switch {
+	case mean < 1 && (code == 61 || code == 63 || code == 80 || code == 95):
+		code = 71 // Too cold for rain
+	case mean > 4 && code == 71:
+		code = 61 // Too warm for snow
+	}
+	f := &Forecast{
+		Date:          date,
+		Timezone:      timezone,
+		Temperature:   math.Round(mean*10) / 10,
+		High:          math.Round((mean+spread)*10) / 10,
+		Low:           math.Round((mean-spread)*10) / 10,
+		ConditionCode: code,
+		Condition:     ConditionName(code),
+		FetchedAt:     time.Now(),
+	}
+	if code >= 51 {
+		f.PrecipitationProbability = 60 + int(seed%40)
+		f.Precipitation = float64(seed%150) / 10
+	}
+	return f, nil
+}
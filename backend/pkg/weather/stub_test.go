@@ -0,0 +1,47 @@
+package weather
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"banana-weather/pkg/gazetteer"
+)
+
+func TestStub(t *testing.T) {
+	zones, err := gazetteer.NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		zones    *gazetteer.Service
+		lat, lng float64
+		want     string
+	}{
+		{zones, 35.68, 139.69, "Asia/Tokyo"},
+		{zones, -33.87, 151.21, "Australia/Sydney"},
+		{nil, 35.68, 139.69, "Etc/GMT-9"},
+		{nil, 40.71, -74.01, "Etc/GMT+5"},
+	}
+	for _, tt := range tests {
+		f, err := NewStub(tt.zones).GetForecast(context.Background(), tt.lat, tt.lng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Timezone != tt.want {
+			t.Errorf("forecast at %v, %v in %q, want %q", tt.lat, tt.lng, f.Timezone, tt.want)
+		}
+		zone, _ := time.LoadLocation(tt.want)
+		if today := time.Now().In(zone).Format("2006-01-02"); f.Date != today {
+			t.Errorf("forecast at %v, %v for %s, want the local date %s", tt.lat, tt.lng, f.Date, today)
+		}
+		if f.Low > f.Temperature || f.Temperature > f.High || f.Condition == "" {
+			t.Errorf("implausible forecast %+v", f)
+		}
+
+		again, _ := NewStub(tt.zones).GetForecast(context.Background(), tt.lat, tt.lng)
+		if again.Date == f.Date && (again.Temperature != f.Temperature || again.ConditionCode != f.ConditionCode) {
+			t.Errorf("forecast changed within a day: %+v, then %+v", f, again)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// Provider returns structured weather data for a coordinate.
+type Provider interface {
+	GetForecast(ctx context.Context, lat, lng float64) (*Forecast, error)
+}
+
+// Forecast is the current conditions plus today's outlook for a location.
+// Temperatures are in °C and precipitation in millimetres.
+type Forecast struct {
+	Date                     string    `firestore:"date" json:"date"` // Local date, YYYY-MM-DD
+	Timezone                 string    `firestore:"timezone" json:"timezone,omitempty"`
+	Temperature              float64   `firestore:"temperature" json:"temperature"` // Current
+	High                     float64   `firestore:"high" json:"high"`
+	Low                      float64   `firestore:"low" json:"low"`
+	ConditionCode            int       `firestore:"condition_code" json:"condition_code"` // WMO weather code
+	Condition                string    `firestore:"condition" json:"condition"`
+	Precipitation            float64   `firestore:"precipitation" json:"precipitation"` // Today's total
+	PrecipitationProbability int       `firestore:"precipitation_probability" json:"precipitation_probability"`
+	FetchedAt                time.Time `firestore:"fetched_at" json:"fetched_at"`
+}
+
+// Fingerprint is the part of a forecast that makes a generated image right or
+// wrong: the date, the conditions and the day's range. The current
+// temperature is left out; it changes all day.
+type Fingerprint struct {
+	Date          string  `firestore:"date" json:"date"`
+	ConditionCode int     `firestore:"condition_code" json:"condition_code"`
+	High          float64 `firestore:"high" json:"high"`
+	Low           float64 `firestore:"low" json:"low"`
+}
+
+// Fingerprint returns f's Fingerprint.
+func (f *Forecast) Fingerprint() *Fingerprint {
+	return &Fingerprint{
+		Date:          f.Date,
+		ConditionCode: f.ConditionCode,
+		High:          f.High,
+		Low:           f.Low,
+	}
+}
+
+// ChangedMaterially reports whether other describes noticeably different
+// weather: another day or condition, or a high or low that moved by more than
+// threshold °C.
+func (fp *Fingerprint) ChangedMaterially(other *Fingerprint, threshold float64) bool {
+	return fp.Date != other.Date ||
+		fp.ConditionCode != other.ConditionCode ||
+		math.Abs(fp.High-other.High) > threshold ||
+		math.Abs(fp.Low-other.Low) > threshold
+}
+
+// NewProvider returns the Provider selected by WEATHER_PROVIDER:
+//   - "openmeteo" (default): live data from the Open-Meteo API.
+//   - "stub": deterministic offline data, in nautical timezones.
+//   - "none": no provider; the image model looks the weather up itself.
+func NewProvider() (Provider, error) {
+	switch name := os.Getenv("WEATHER_PROVIDER"); name {
+	case "", "openmeteo":
+		return NewOpenMeteo(os.Getenv("WEATHER_API_URL")), nil
+	case "stub":
+		return NewStub(nil), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown WEATHER_PROVIDER %q (want openmeteo, stub or none)", name)
+	}
+}
+
+// PromptText renders the forecast as instructions for the image model.
+func (f *Forecast) PromptText() string {
+	date := f.Date
+	if d, err := time.Parse("2006-01-02", f.Date); err == nil {
+		date = d.Format("Monday, January 2, 2006")
+	}
+	return fmt.Sprintf(`Use exactly this forecast (do not look up the weather yourself):
+- Date: %s
+- Conditions: %s
+- Temperature range: %s to %s (currently %s)
+- Precipitation: %.1f mm (%d%% chance)
+Show temperatures in the unit customary for the city.`,
+		date, f.Condition,
+		formatTemp(f.Low), formatTemp(f.High), formatTemp(f.Temperature),
+		f.Precipitation, f.PrecipitationProbability)
+}
+
+func formatTemp(c float64) string {
+	return fmt.Sprintf("%d°C / %d°F", int(math.Round(c)), int(math.Round(c*9/5+32)))
+}
+
+// ConditionName describes a WMO weather interpretation code.
+func ConditionName(code int) string {
+	switch code {
+	case 0:
+		return "Clear sky"
+	case 1:
+		return "Mainly clear"
+	case 2:
+		return "Partly cloudy"
+	case 3:
+		return "Overcast"
+	case 45, 48:
+		return "Fog"
+	case 51, 53, 55:
+		return "Drizzle"
+	case 56, 57:
+		return "Freezing drizzle"
+	case 61:
+		return "Light rain"
+	case 63:
+		return "Rain"
+	case 65:
+		return "Heavy rain"
+	case 66, 67:
+		return "Freezing rain"
+	case 71:
+		return "Light snow"
+	case 73:
+		return "Snow"
+	case 75:
+		return "Heavy snow"
+	case 77:
+		return "Snow grains"
+	case 80, 81, 82:
+		return "Rain showers"
+	case 85, 86:
+		return "Snow showers"
+	case 95:
+		return "Thunderstorm"
+	case 96, 99:
+		return "Thunderstorm with hail"
+	default:
+		return "Unknown"
+	}
+}
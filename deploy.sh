@@ -84,13 +84,15 @@ echo "GenAI Location: $LOCATION"
 echo "Bucket: $GENMEDIA_BUCKET"
 echo "Firestore DB: $FIRESTORE_DATABASE"
 
+# Cloud Run's front end connects from a link-local address and appends the
+# client's address to X-Forwarded-For.
 ARGS=(
   "$SERVICE_NAME"
   "--source" "."
   "--project" "$PROJECT_ID"
   "--region" "$REGION"
   "--allow-unauthenticated"
-  "--set-env-vars" "GOOGLE_MAPS_API_KEY=$GOOGLE_MAPS_API_KEY,GOOGLE_CLOUD_PROJECT=$PROJECT_ID,GOOGLE_CLOUD_LOCATION=$LOCATION,GENMEDIA_BUCKET=$GENMEDIA_BUCKET,FIRESTORE_DATABASE=$FIRESTORE_DATABASE"
+  "--set-env-vars" "GOOGLE_MAPS_API_KEY=$GOOGLE_MAPS_API_KEY,GOOGLE_CLOUD_PROJECT=$PROJECT_ID,GOOGLE_CLOUD_LOCATION=$LOCATION,GENMEDIA_BUCKET=$GENMEDIA_BUCKET,FIRESTORE_DATABASE=$FIRESTORE_DATABASE,RATE_LIMIT_TRUSTED_PROXIES=169.254.0.0/16"
 )
 
 # If you created a specific SA, uncomment the line below:
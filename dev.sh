@@ -10,6 +10,7 @@
 #
 # Options:
 #   --quick    Skip Flutter build (Use this if you haven't changed frontend code)
+#   --dev      Run the backend offline with fake providers (no cloud credentials needed)
 #
 
 # Load environment variables from .env
@@ -22,16 +23,22 @@ else
   echo "Warning: .env file not found. Ensure environment variables are set manually."
 fi
 
-# Check for required keys
-if [ -z "$GOOGLE_MAPS_API_KEY" ]; then
-  echo "Error: GOOGLE_MAPS_API_KEY in environment."
-  echo "Please create a .env file or export these variables."
-  exit 1
-fi
-
-if [ -z "$PROJECT_ID" ] && [ -z "$GOOGLE_CLOUD_PROJECT" ]; then
-    echo "Error: PROJECT_ID or GOOGLE_CLOUD_PROJECT not set."
+server_args=""
+if [[ "$*" == *"--dev"* ]] || [ "$BANANA_MODE" == "fake" ]; then
+  echo "🧪 Dev mode: using offline fake providers"
+  server_args="--dev"
+else
+  # Check for required keys
+  if [ -z "$GOOGLE_MAPS_API_KEY" ]; then
+    echo "Error: GOOGLE_MAPS_API_KEY in environment."
+    echo "Please create a .env file or export these variables."
     exit 1
+  fi
+
+  if [ -z "$PROJECT_ID" ] && [ -z "$GOOGLE_CLOUD_PROJECT" ]; then
+      echo "Error: PROJECT_ID or GOOGLE_CLOUD_PROJECT not set."
+      exit 1
+  fi
 fi
 
 # 1. Frontend Build (Flutter)
@@ -59,7 +66,7 @@ if [ $? -eq 0 ]; then
   echo "✅ Server starting on port $port..."
   echo "   - Web App: http://localhost:$port"
   echo "   - API:     http://localhost:$port/api/weather"
-  ./server
+  ./server $server_args
 else
   echo "❌ Backend build failed."
   exit 1
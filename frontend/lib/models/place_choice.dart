@@ -0,0 +1,27 @@
+/// One of the places an ambiguous city name may mean, from a `choices` event
+/// or /api/geocode.
+class PlaceChoice {
+  final String name;
+  final String placeId;
+  final String? country;
+  final double lat;
+  final double lng;
+
+  PlaceChoice({
+    required this.name,
+    required this.placeId,
+    this.country,
+    required this.lat,
+    required this.lng,
+  });
+
+  factory PlaceChoice.fromJson(Map<String, dynamic> json) {
+    return PlaceChoice(
+      name: json['name'],
+      placeId: json['place_id'],
+      country: json['country'],
+      lat: (json['lat'] as num).toDouble(),
+      lng: (json['lng'] as num).toDouble(),
+    );
+  }
+}
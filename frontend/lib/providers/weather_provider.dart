@@ -3,6 +3,7 @@ import 'package:flutter/foundation.dart';
 import 'package:flutter/material.dart';
 import 'package:http/http.dart' as http;
 import 'package:geolocator/geolocator.dart';
+import '../models/place_choice.dart';
 import '../models/preset.dart';
 
 class WeatherProvider with ChangeNotifier {
@@ -13,8 +14,18 @@ class WeatherProvider with ChangeNotifier {
   String? _error;
   String? _statusMessage;
   String? _videoUrl;
+  bool _isStale = false; // Showing a cached forecast while a fresh one is made
   List<Preset> _presets = [];
   bool _isPresetLoaded = false;
+  List<PlaceChoice> _choices = []; // Places an ambiguous city name may mean
+
+  // Generation jobs outlive the HTTP stream; these let us resume after a drop.
+  static const int _maxReconnects = 3;
+  String? _jobId;
+  String? _lastEventId;
+  bool _jobDone = false;
+  int _reconnects = 0;
+  int _requestSerial = 0;
 
   String? get city => _city;
   String? get imageBase64 => _imageBase64;
@@ -25,12 +36,18 @@ class WeatherProvider with ChangeNotifier {
   String? get videoUrl => _videoUrl;
   List<Preset> get presets => _presets;
   bool get isPresetLoaded => _isPresetLoaded;
+  List<PlaceChoice> get choices => _choices;
 
   void clearError() {
     _error = null;
     notifyListeners();
   }
 
+  void clearChoices() {
+    _choices = [];
+    notifyListeners();
+  }
+
   void loadPreset(Preset p) {
     _city = p.name;
     _imageUrl = p.imageUrl;
@@ -88,30 +105,49 @@ class WeatherProvider with ChangeNotifier {
     }
   }
 
-  Future<void> fetchWeather({String? city, double? lat, double? lng}) async {
+  Future<void> fetchWeather({String? city, double? lat, double? lng, String? placeId}) async {
     _isLoading = true;
     _error = null;
+    _choices = [];
     _statusMessage = "Connecting...";
     _videoUrl = null;
     _imageUrl = null; // Clear preset image
     _imageBase64 = null;
+    _isStale = false;
     _isPresetLoaded = false;
     notifyListeners();
 
-    try {
-      final String baseUrl = kDebugMode ? 'http://localhost:8080' : '';
+    _jobId = null;
+    _lastEventId = null;
+    _jobDone = false;
+    _reconnects = 0;
+    final int serial = ++_requestSerial;
 
-      final Uri uri;
-      if (lat != null && lng != null) {
-        uri = Uri.parse('$baseUrl/api/weather?lat=$lat&lng=$lng');
-      } else if (city != null && city.isNotEmpty) {
-        uri = Uri.parse('$baseUrl/api/weather?city=$city');
-      } else {
-         uri = Uri.parse('$baseUrl/api/weather?city=San Francisco');
-      }
-      
+    final String baseUrl = kDebugMode ? 'http://localhost:8080' : '';
+
+    final Uri uri;
+    if (placeId != null) {
+      uri = Uri.parse('$baseUrl/api/weather').replace(queryParameters: {'place_id': placeId});
+    } else if (lat != null && lng != null) {
+      // Six decimals is about 10 cm; toString may use up to 17 significant
+      // digits or an exponent, which the backend rejects.
+      uri = Uri.parse('$baseUrl/api/weather?lat=${lat.toStringAsFixed(6)}&lng=${lng.toStringAsFixed(6)}');
+    } else if (city != null && city.isNotEmpty) {
+      uri = Uri.parse('$baseUrl/api/weather?city=$city');
+    } else {
+       uri = Uri.parse('$baseUrl/api/weather?city=San Francisco');
+    }
+
+    await _connect(uri, serial);
+  }
+
+  Future<void> _connect(Uri uri, int serial) async {
+    try {
       final request = http.Request('GET', uri);
       request.headers['Accept'] = 'text/event-stream';
+      if (_lastEventId != null) {
+        request.headers['Last-Event-ID'] = _lastEventId!;
+      }
 
       final client = http.Client();
       final response = await client.send(request);
@@ -130,7 +166,10 @@ class WeatherProvider with ChangeNotifier {
           .transform(const LineSplitter())
           .listen(
         (line) {
-          if (line.startsWith('event:')) {
+          if (serial != _requestSerial) return; // Superseded by a newer request
+          if (line.startsWith('id:')) {
+            _lastEventId = line.substring(3).trim();
+          } else if (line.startsWith('event:')) {
             currentEvent = line.substring(6).trim();
           } else if (line.startsWith('data:')) {
             final data = line.substring(5).trim();
@@ -138,31 +177,65 @@ class WeatherProvider with ChangeNotifier {
           }
         },
         onError: (e) {
+          if (_resume(serial)) return;
           _error = 'Stream error: $e';
           _isLoading = false;
           notifyListeners();
         },
         onDone: () {
-          // If we finished without a result, that might be an issue, 
-          // but usually 'result' event handles the completion.
+          // The server always ends a job with a 'done' event; anything else
+          // means the connection dropped mid-generation.
+          _resume(serial);
         },
       );
 
     } catch (e) {
+      if (_resume(serial)) return;
       _error = 'Error: $e';
       _isLoading = false;
       notifyListeners();
     }
   }
 
+  /// Reconnects to the current job after a dropped stream. Returns false if
+  /// there is nothing to resume.
+  bool _resume(int serial) {
+    if (serial != _requestSerial || _jobDone || _jobId == null || _reconnects >= _maxReconnects) {
+      return false;
+    }
+    _reconnects++;
+    final String baseUrl = kDebugMode ? 'http://localhost:8080' : '';
+    final uri = Uri.parse('$baseUrl/api/weather?job=$_jobId');
+    Future.delayed(Duration(seconds: 2 * _reconnects), () => _connect(uri, serial));
+    return true;
+  }
+
   void _handleEvent(String event, String data) {
     switch (event) {
+      case 'job':
+        _jobId = data;
+        break;
+      case 'done':
+        _jobDone = true;
+        break;
+      case 'choices':
+        // The city name is ambiguous; the user picks one and we ask again.
+        try {
+          final List<dynamic> list = json.decode(data)['choices'];
+          _choices = list.map((json) => PlaceChoice.fromJson(json)).toList();
+        } catch (e) {
+          _error = "Failed to parse choices";
+        }
+        _isLoading = false;
+        _statusMessage = null;
+        notifyListeners();
+        break;
       case 'status':
         _statusMessage = data;
         notifyListeners();
         break;
       case 'error':
-        _error = data;
+        _error = _errorMessage(data);
         _isLoading = false;
         _statusMessage = null;
         notifyListeners();
@@ -170,6 +243,11 @@ class WeatherProvider with ChangeNotifier {
       case 'result':
         try {
           final jsonData = json.decode(data);
+          final bool stale = jsonData['stale'] == true;
+          if (_isStale && !stale) {
+            _videoUrl = null; // The stale video no longer matches the image
+          }
+          _isStale = stale;
           _city = jsonData['city'];
           _imageBase64 = jsonData['image_base64']; // Null if missing
           _imageUrl = jsonData['image_url'];       // Null if missing
@@ -183,11 +261,29 @@ class WeatherProvider with ChangeNotifier {
         }
         break;
       case 'video':
-        _videoUrl = data;
+        // Stale videos come as JSON ({"url": ..., "stale": true}), fresh
+        // ones as a plain URL.
+        _videoUrl = data.startsWith('{') ? json.decode(data)['url'] : data;
         // If we receive a video, we are effectively "done" with the heavy lifting for this session
         _statusMessage = null; 
         notifyListeners();
         break;
     }
   }
+
+  /// Error events are either a plain message or JSON with a `message` field
+  /// (plus a machine-readable `code`, e.g. `rate_limited`).
+  String _errorMessage(String data) {
+    if (data.startsWith('{')) {
+      try {
+        final json = jsonDecode(data);
+        if (json is Map<String, dynamic> && json['message'] is String) {
+          return json['message'];
+        }
+      } catch (_) {
+        // Not JSON after all; show it as is.
+      }
+    }
+    return data;
+  }
 }
@@ -255,6 +255,55 @@ class _HomeScreenState extends State<HomeScreen> {
                     ),
                   ),
 
+                // Place Choices (ambiguous city name)
+                if (weatherProvider.choices.isNotEmpty)
+                  Positioned(
+                    top: 10,
+                    left: 20,
+                    right: 20,
+                    child: Container(
+                      padding: const EdgeInsets.all(8),
+                      decoration: BoxDecoration(
+                        color: Colors.black.withOpacity(0.8),
+                        borderRadius: BorderRadius.circular(8),
+                        border: Border.all(color: Colors.white10),
+                      ),
+                      child: Column(
+                        mainAxisSize: MainAxisSize.min,
+                        crossAxisAlignment: CrossAxisAlignment.stretch,
+                        children: [
+                          Row(
+                            children: [
+                              Expanded(
+                                child: Text(
+                                  "Which one did you mean?",
+                                  style: GoogleFonts.lato(color: Colors.white, fontWeight: FontWeight.bold),
+                                ),
+                              ),
+                              IconButton(
+                                icon: const Icon(Icons.close, color: Colors.white, size: 20),
+                                onPressed: () {
+                                  weatherProvider.clearChoices();
+                                },
+                                padding: EdgeInsets.zero,
+                                constraints: const BoxConstraints(),
+                              ),
+                            ],
+                          ),
+                          for (final choice in weatherProvider.choices)
+                            ListTile(
+                              dense: true,
+                              leading: const Icon(Icons.place, color: Colors.yellowAccent),
+                              title: Text(choice.name, style: const TextStyle(color: Colors.white)),
+                              onTap: () {
+                                weatherProvider.fetchWeather(placeId: choice.placeId);
+                              },
+                            ),
+                        ],
+                      ),
+                    ),
+                  ),
+
                 // Error Message
                 if (weatherProvider.error != null)
                   Positioned(